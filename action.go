@@ -4,6 +4,7 @@ import (
 	"log"
 	"math"
 	"math/rand"
+	"strings"
 	"time"
 
 	"golang.org/x/mobile/exp/sprite/clock"
@@ -12,6 +13,7 @@ import (
 const (
 	TwoPi  = math.Pi * 2
 	HalfPi = math.Pi / 2
+	Sqrt3  = 1.7320508075688772
 )
 
 func init() {
@@ -72,7 +74,16 @@ func blockSprite(o *Object) {
 		log.Println("Invalid type assertion", o.Data)
 		return
 	}
-	o.Sprite = g.world.texs[colorTexMap[b.Color]]
+	o.Sprite = g.world.texs[blockTex(b.Color, g.level.hex)]
+}
+
+// blockTex returns the texture of the block color.
+func blockTex(c Color, hex bool) int {
+	tex := colorTexMap[c]
+	if hex && tex != texEmpty {
+		tex += texHexRed - texBlockRed
+	}
+	return tex
 }
 
 func blockIdle(o *Object, t clock.Time) {
@@ -89,39 +100,67 @@ func blockIdle(o *Object, t clock.Time) {
 	}
 }
 
-func signatureBlockIdle(o *Object, t clock.Time) {
-	blockSprite(o)
+// blockHighlightIdle outlines the block if the switch
+// under the mouse turns it.
+func blockHighlightIdle(o *Object, t clock.Time) {
+	if g.hovered(o.Data.(*Block)) {
+		o.Sprite = g.world.texs[texHighlight]
+	} else {
+		o.Sprite = g.world.texs[texEmpty]
+	}
+}
+
+func lockIdle(o *Object, t clock.Time) {
+	// Hide the lock with the blocks once the level is won
+	o.Dead = g.level.Win()
 }
 
 func switchRotate(o *Object, t clock.Time) {
+	switchTurn(o, t, TwoPi)
+}
+
+func switchRotateInverse(o *Object, t clock.Time) {
+	switchTurn(o, t, -TwoPi)
+}
+
+func switchTurn(o *Object, t clock.Time, angle float32) {
 	if o.Time == 0 {
 		o.Time = t
 	}
 	f := clock.EaseOut(o.Time, o.Time+15, t)
-	o.AngleCenter = TwoPi * f
+	o.AngleCenter = angle * f
 	if f == 1 {
 		o.Reset()
 		o.Action = ActionFunc(switchIdle)
 	}
 }
 
-func blockRotate(o *Object, t clock.Time) {
+const (
+	rotateDuration = 15
+	undoDuration   = 12
+)
+
+// turn animates the rotation of a block around its pivot.
+// The angle is a quarter turn for the 2x2 switches.
+type turn struct {
+	angle    float32
+	duration clock.Time
+	// from is the part of the rotation already done
+	from float32
+}
+
+func (r turn) Do(o *Object, t clock.Time) {
 	if o.Time == 0 {
 		o.Time = t
 	}
-	f := clock.EaseOut(o.Time, o.Time+15, t)
-	o.Angle = HalfPi * f
+	e := clock.EaseOut(o.Time, o.Time+r.duration, t)
+	f := r.from + (1-r.from)*e
+	o.Angle = r.angle * f
 	o.AngleCenter = -o.Angle
-	if f == 1 {
+	if e == 1 {
 		// The rotation is over
 		// First apply the rotation to the level struct
-		// Use a mutex because this must be done only one time
-		g.level.Lock()
-		if g.level.rotating != nil {
-			g.level.RotateSwitch(g.level.rotating)
-		}
-		g.level.rotating = nil
-		g.level.Unlock()
+		g.level.applyRotating()
 		// Apply the new sprite
 		blockSprite(o)
 		o.Reset()
@@ -131,61 +170,113 @@ func blockRotate(o *Object, t clock.Time) {
 	}
 	blockSprite(o)
 	// Update also the scaling
+	o.Scale = turnScale(f)
+}
+
+// turnScale shrinks the blocks in the middle of a rotation,
+// f is the part of the rotation done.
+func turnScale(f float32) float32 {
 	if f > .5 {
 		f = (f - .5) / .5
-		o.Scale = .8 + .2*f
-	} else {
-		f = f / .5
-		o.Scale = 1 - .2*f
+		return .8 + .2*f
 	}
+	f = f / .5
+	return 1 - .2*f
 }
 
-func blockInLaw(o *Object, t clock.Time) {
+// blockFollow turns the block with the player finger,
+// and snaps it back once released.
+func blockFollow(o *Object, t clock.Time) {
+	blockSprite(o)
+	if g.drag == nil || !g.drag.dragging {
+		o.Time = 0
+		o.Action = ActionFunc(blockInLaw)
+		return
+	}
+	o.Angle = g.drag.angle
+	o.AngleCenter = -o.Angle
+	o.Scale = turnScale(g.drag.part())
+}
+
+// flip shrinks the block, then grows it back with the next
+// color, the one it will have once the move is applied. Used
+// when the blocks can't turn around a pivot.
+type flip struct {
+	next     Color
+	duration clock.Time
+}
+
+func (r flip) Do(o *Object, t clock.Time) {
 	if o.Time == 0 {
 		o.Time = t
+		o.Sx = o.X + o.Width/2
+		o.Sy = o.Y + o.Height/2
 	}
-	f := clock.EaseOut(o.Time, o.Time+8, t)
-	o.Angle = o.Angle - o.Angle*f
+	f := clock.Linear(o.Time, o.Time+r.duration, t)
+	if f < .5 {
+		blockSprite(o)
+		o.Scale = 1 - f*2
+		return
+	}
+	o.Sprite = g.world.texs[blockTex(r.next, g.level.hex)]
+	o.Scale = f*2 - 1
 	if f == 1 {
-		// Animation over go back to idle
+		// Applied at the same time as the other
+		// switches of the move, which last as long.
+		g.level.applyRotating()
+		blockSprite(o)
 		o.Reset()
 		o.Action = ActionFunc(blockIdle)
-		return
 	}
 }
 
-func blockRotateInverse(o *Object, t clock.Time) {
+// slide translates the block to its next cell
+// for the row and column shifts. The block wrapping
+// around the board shrinks out of its edge, then grows
+// back on the opposite edge, moved by wx, wy.
+type slide struct {
+	dx, dy   float32
+	wx, wy   float32
+	duration clock.Time
+}
+
+func (r slide) Do(o *Object, t clock.Time) {
 	if o.Time == 0 {
 		o.Time = t
 	}
-	f := clock.EaseOut(o.Time, o.Time+12, t)
-	o.Angle = -HalfPi * f
-	o.AngleCenter = -o.Angle
-	if f == 1 {
-		// The rotation is over
-		// First apply the rotation to the level struct
-		// Use a mutex because this must be done only one time
-		g.level.Lock()
-		if g.level.rotating != nil {
-			g.level.RotateSwitchInverse(g.level.rotating)
+	blockSprite(o)
+	f := clock.EaseInOut(o.Time, o.Time+r.duration, t)
+	o.Tx, o.Ty = r.dx*f, r.dy*f
+	if r.wx != 0 || r.wy != 0 {
+		o.Sx = o.X + o.Width/2
+		o.Sy = o.Y + o.Height/2
+		if f < .5 {
+			o.Scale = 1 - f*2
+		} else {
+			o.Tx, o.Ty = o.Tx+r.wx, o.Ty+r.wy
+			o.Scale = f*2 - 1
 		}
-		g.level.rotating = nil
-		g.level.Unlock()
-		// Apply new sprite
+	}
+	if f == 1 {
+		g.level.applyRotating()
 		blockSprite(o)
 		o.Reset()
-		// Now idle
 		o.Action = ActionFunc(blockIdle)
-		return
 	}
-	blockSprite(o)
-	// Update also the scaling
-	if f > .5 {
-		f = (f - .5) / .5
-		o.Scale = .8 + .2*f
-	} else {
-		f = f / .5
-		o.Scale = 1 - .2*f
+}
+
+func blockInLaw(o *Object, t clock.Time) {
+	if o.Time == 0 {
+		o.Time = t
+	}
+	f := clock.EaseOut(o.Time, o.Time+8, t)
+	o.Angle = o.Angle - o.Angle*f
+	o.AngleCenter = -o.Angle
+	if f == 1 {
+		// Animation over go back to idle
+		o.Reset()
+		o.Action = ActionFunc(blockIdle)
+		return
 	}
 }
 
@@ -236,7 +327,7 @@ func switchPopIn(o *Object, t clock.Time) {
 	if o.Scale == 1 {
 		o.Reset()
 		o.Action = ActionFunc(switchIdle)
-		g.listen = true
+		g.state.Fire(OnIntroDone, t)
 	}
 }
 
@@ -257,15 +348,80 @@ func switchIdle(o *Object, t clock.Time) {
 		return
 	}
 	switchSprite(o)
+	if g.hover == o.Data && g.state.State() == Playing {
+		// Grow the switch under the mouse
+		o.Sx, o.Sy = o.X+o.Width/2, o.Y+o.Height/2
+		o.Scale = hoverScale
+	} else {
+		o.Sx, o.Sy, o.Scale = 0, 0, 0
+	}
+}
+
+// switchGhost mirrors the switch on the opposite board edge.
+func switchGhost(o *Object, t clock.Time) {
+	sw, ok := o.Data.(*Switch)
+	if !ok {
+		log.Println("Invalid type assertion", o.Data)
+		return
+	}
+	o.Sprite = sw.Sprite
+	o.Dead = sw.Dead
+	o.AngleCenter = sw.AngleCenter
+	o.Scale = sw.Scale
+	if sw.Sx > 0 {
+		o.Sx = o.X + o.Width/2
+		o.Sy = o.Y + o.Height/2
+	} else {
+		o.Sx, o.Sy = 0, 0
+	}
+}
+
+// switchUsesIdle displays the remaining uses of a switch.
+func switchUsesIdle(o *Object, t clock.Time) {
+	sw, ok := o.Data.(*Switch)
+	if !ok {
+		log.Println("Invalid type assertion", o.Data)
+		return
+	}
+	o.Dead = g.level.Win()
+	o.Sprite = g.world.texs[tex0+sw.remaining]
+}
+
+func switchKeyIdle(o *Object, t clock.Time) {
+	sw, ok := o.Data.(*Switch)
+	if !ok {
+		log.Println("Invalid type assertion", o.Data)
+		return
+	}
+	if !g.keyboard || g.level.Win() {
+		o.Sprite = g.world.texs[texEmpty]
+		return
+	}
+	o.Sprite = g.world.texs[tex0+int(sw.name[0]-'0')]
 }
 
 func switchSprite(o *Object) {
-	_, ok := o.Data.(*Switch)
+	sw, ok := o.Data.(*Switch)
 	if !ok {
 		log.Println("Invalid type assertion", o.Data)
 		return
 	}
-	o.Sprite = g.world.texs[texSwitch1]
+	switch {
+	case sw.kind == RowShift && sw.dir == CounterClockwise:
+		o.Sprite = g.world.texs[texArrowLeft]
+	case sw.kind == RowShift:
+		o.Sprite = g.world.texs[texArrowRight]
+	case sw.kind == ColShift && sw.dir == CounterClockwise:
+		o.Sprite = g.world.texs[texArrowUp]
+	case sw.kind == ColShift:
+		o.Sprite = g.world.texs[texArrowDown]
+	case sw.dir == CounterClockwise:
+		o.Sprite = g.world.texs[texSwitchCCW]
+	case sw.dir == BothWays:
+		o.Sprite = g.world.texs[texSwitchBoth]
+	default:
+		o.Sprite = g.world.texs[texSwitch1]
+	}
 
 	//switch sw.name {
 	//case "1":
@@ -290,9 +446,8 @@ func switchSprite(o *Object) {
 }
 
 func looseTxtPop(o *Object, t clock.Time) {
-	o.Dead = !g.level.Loose()
+	o.Dead = !g.Lost()
 	if !o.Dead {
-		g.listen = false
 		if o.Time == 0 {
 			o.Time = t
 			o.Sx = o.X + o.Width/2
@@ -304,10 +459,6 @@ func looseTxtPop(o *Object, t clock.Time) {
 		if f == 1 {
 			o.Reset()
 			o.Action = &swing{}
-			go func() {
-				time.Sleep(time.Second)
-				g.listen = true
-			}()
 		}
 
 	}
@@ -350,8 +501,6 @@ func (s *swing) Do(o *Object, t clock.Time) {
 func winTxtPop(o *Object, t clock.Time) {
 	o.Dead = !g.level.Win()
 	if !o.Dead {
-		// Wait until animation done
-		g.listen = false
 		if o.Time == 0 {
 			// Set time for the first pass
 			o.Time = t
@@ -364,15 +513,69 @@ func winTxtPop(o *Object, t clock.Time) {
 			// First animation is over
 			o.Reset()
 			o.Action = ActionFunc(winTxtZoomIn)
-			go func() {
-				// Wait before let the user go forward.
-				time.Sleep(time.Second)
-				g.listen = true
-			}()
 		}
 	}
 }
 
+// heartIdle displays the life if the player still has it.
+func heartIdle(o *Object, t clock.Time) {
+	if o.Data.(int) < g.Lives() {
+		o.Sprite = g.world.texs[texHeart]
+	} else {
+		o.Sprite = g.world.texs[texEmpty]
+	}
+}
+
+func buttonIdle(o *Object, t clock.Time) {
+	b := o.Data.(*Button)
+	if b.usable() {
+		o.Sprite = g.world.texs[b.tex]
+	} else {
+		o.Sprite = g.world.texs[texEmpty]
+	}
+}
+
+// promptChar displays a character of the typed code, Data
+// is its position from the end. The text shakes when the
+// code is refused.
+func promptChar(o *Object, t clock.Time) {
+	if g.prompt == nil {
+		return
+	}
+	i := len(g.prompt.text) - o.Data.(int)
+	if i < 0 {
+		o.Sprite = g.world.texs[texEmpty]
+		return
+	}
+	o.Sprite = g.world.glyph(strings.IndexByte(codeAlphabet, g.prompt.text[i]))
+	o.Tx = 0
+	if f := g.prompt.failed; f != 0 && t < f+30 {
+		o.Tx = float32(math.Sin(float64(t-f)*math.Pi/5)) * o.Width / 2
+	}
+}
+
+// starPop displays the stars of the level score below
+// the win text, Data is the star index.
+func starPop(o *Object, t clock.Time) {
+	o.Dead = !g.level.Win()
+	if o.Dead {
+		return
+	}
+	i := o.Data.(int)
+	if i < g.level.Score().Stars {
+		o.Sprite = g.world.texs[texStarFull]
+	} else {
+		o.Sprite = g.world.texs[texStarEmpty]
+	}
+	if o.Time == 0 {
+		// Pop after the win text, one star after the other
+		o.Time = t + clock.Time(20+i*10)
+		o.Sx = o.X + o.Width/2
+		o.Sy = o.Y + o.Height/2
+	}
+	o.Scale = clock.EaseInOut(o.Time, o.Time+20, t)
+}
+
 func winTxtZoomIn(o *Object, t clock.Time) {
 	if o.Time == 0 {
 		// Start the animation
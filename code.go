@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// Level codes are compact strings used to share custom levels.
+// The payload is made of :
+// - the code version
+// - the board dimensions (4 bits for lines, 4 bits for columns)
+// - the max move count
+// - the board then the win colors, 5 bits per block
+// - the locked blocks, 1 bit per block
+// - the switches as written in the level files, separated by ';'
+//
+// A 2 bytes checksum is appended and the whole is base64url encoded.
+// The switches of the wrapping levels start with the Wrap header.
+const (
+	codeVersion  = 1
+	codeColorLen = 5
+	codeSumLen   = 2
+	// codePalette lists the colors in their code order,
+	// followed by the Ignored win cells.
+	codePalette = "-0123456789ABCDEF."
+)
+
+var errInvalidCode = errors.New("invalid level code")
+
+// EncodeLevel returns the shareable code of the level.
+func EncodeLevel(l *Level) (string, error) {
+	if err := l.Validate(); err != nil {
+		return "", err
+	}
+	if l.hex {
+		return "", errors.New("hex levels can't be encoded")
+	}
+	if _, ok := l.goal.(signatureGoal); !ok {
+		return "", errors.New("pattern goals can't be encoded")
+	}
+	lines, cols := len(l.blocks), len(l.blocks[0])
+	if lines > 15 || cols > 15 || l.maxMoves > 255 {
+		return "", errors.New("level too large to be encoded")
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(codeVersion)
+	buf.WriteByte(byte(lines<<4 | cols))
+	buf.WriteByte(byte(l.maxMoves))
+
+	bw := &bitWriter{buf: &buf}
+	for _, grid := range [][][]Color{l.colors(), l.winSignature} {
+		for i := range grid {
+			for j := range grid[i] {
+				bw.write(strings.IndexRune(codePalette, rune(grid[i][j])), codeColorLen)
+			}
+		}
+	}
+	for i := range l.blocks {
+		for j := range l.blocks[i] {
+			if l.blocks[i][j].locked {
+				bw.write(1, 1)
+			} else {
+				bw.write(0, 1)
+			}
+		}
+	}
+	bw.flush()
+
+	var sws []string
+	if l.wrap {
+		sws = append(sws, Wrap)
+	}
+	for _, sw := range l.switches {
+		sws = append(sws, sw.format())
+	}
+	buf.WriteString(strings.Join(sws, ";"))
+
+	sum := crc32.ChecksumIEEE(buf.Bytes())
+	buf.WriteByte(byte(sum >> 8))
+	buf.WriteByte(byte(sum))
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecodeLevel reads a level code and returns the corresponding level.
+func DecodeLevel(code string) (l *Level, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(code))
+	if err != nil || len(b) < 3+codeSumLen {
+		return nil, errInvalidCode
+	}
+	payload, sum := b[:len(b)-codeSumLen], b[len(b)-codeSumLen:]
+	expected := crc32.ChecksumIEEE(payload)
+	if sum[0] != byte(expected>>8) || sum[1] != byte(expected) {
+		return nil, fmt.Errorf("%v: bad checksum", errInvalidCode)
+	}
+	if payload[0] != codeVersion {
+		return nil, fmt.Errorf("%v: unsupported version %d", errInvalidCode, payload[0])
+	}
+	lines, cols := int(payload[1]>>4), int(payload[1]&0xf)
+	maxMoves := int(payload[2])
+	nbColors := lines * cols * 2
+	colorsLen := (nbColors*codeColorLen + lines*cols + 7) / 8
+	if len(payload) < 3+colorsLen {
+		return nil, errInvalidCode
+	}
+
+	// Rebuild the level file and let ParseLevel read it
+	var txt bytes.Buffer
+	switches := strings.Split(string(payload[3+colorsLen:]), ";")
+	if switches[0] == Wrap {
+		txt.WriteString(Wrap + "\n")
+		switches = switches[1:]
+	}
+	br := &bitReader{buf: payload[3 : 3+colorsLen]}
+	colors := make([]int, nbColors)
+	for k := range colors {
+		colors[k] = br.read(codeColorLen)
+	}
+	for k := 0; k < nbColors; k++ {
+		if k == lines*cols {
+			// End of the board, switches come before the win
+			txt.WriteString("\n")
+			for _, sw := range switches {
+				txt.WriteString(sw + "\n")
+			}
+			txt.WriteString("\n")
+		}
+		c := colors[k]
+		if c >= len(codePalette) {
+			return nil, errInvalidCode
+		}
+		txt.WriteByte(codePalette[c])
+		if k < lines*cols && br.read(1) == 1 {
+			txt.WriteRune(Locked)
+		}
+		if (k+1)%cols == 0 {
+			txt.WriteString("\n")
+		}
+	}
+	fmt.Fprintf(&txt, "\n%d\n", maxMoves)
+
+	defer func() {
+		// ParseLevel panics on malformed numbers
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v: %v", errInvalidCode, r)
+		}
+	}()
+	l = ParseLevel(txt.String())
+	if err := l.Validate(); err != nil {
+		return nil, fmt.Errorf("%v: %v", errInvalidCode, err)
+	}
+	return l, nil
+}
+
+// colors returns the current block colors.
+func (l *Level) colors() [][]Color {
+	res := make([][]Color, len(l.blocks))
+	for i := range l.blocks {
+		res[i] = make([]Color, len(l.blocks[i]))
+		for j := range l.blocks[i] {
+			res[i][j] = l.blocks[i][j].Color
+		}
+	}
+	return res
+}
+
+type bitWriter struct {
+	buf  *bytes.Buffer
+	cur  byte
+	nbit uint
+}
+
+// write appends the n lowest bits of v.
+func (w *bitWriter) write(v int, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.cur = w.cur<<1 | byte(v>>uint(i)&1)
+		w.nbit++
+		if w.nbit == 8 {
+			w.buf.WriteByte(w.cur)
+			w.cur, w.nbit = 0, 0
+		}
+	}
+}
+
+func (w *bitWriter) flush() {
+	if w.nbit > 0 {
+		w.buf.WriteByte(w.cur << (8 - w.nbit))
+		w.cur, w.nbit = 0, 0
+	}
+}
+
+type bitReader struct {
+	buf []byte
+	pos uint
+}
+
+// read returns the next n bits.
+func (r *bitReader) read(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		b := r.buf[r.pos/8] >> (7 - r.pos%8) & 1
+		v = v<<1 | int(b)
+		r.pos++
+	}
+	return v
+}
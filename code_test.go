@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const codeLevel = `2*4--
+0313
+5156
+--42
+
+0,0
+1,0
+2,2
+
+22--
+6315
+3150
+--44
+
+50`
+
+func TestEncodeDecodeLevel(t *testing.T) {
+	l := ParseLevel(codeLevel)
+
+	code, err := EncodeLevel(l)
+	assert.Nil(t, err)
+	d, err := DecodeLevel(code)
+
+	assert.Nil(t, err)
+	assert.Equal(t, l.blockSignature(), d.blockSignature())
+	assert.Equal(t, l.winSignature, d.winSignature)
+	assert.Equal(t, 50, d.maxMoves)
+	assert.True(t, d.blocks[0][0].locked)
+	assert.False(t, d.blocks[0][1].locked)
+	assert.Equal(t, 3, len(d.switches))
+	assert.Equal(t, 2, d.switches[2].line)
+	assert.Equal(t, 2, d.switches[2].col)
+}
+
+func TestEncodeDecodeWrappedLevel(t *testing.T) {
+	l := ParseLevel(`wrap
+012
+345
+
+1,2
+
+310
+542
+
+10`)
+
+	code, err := EncodeLevel(l)
+	assert.Nil(t, err)
+	d, err := DecodeLevel(code)
+
+	assert.Nil(t, err)
+	assert.True(t, d.wrap)
+	assert.Equal(t, 1, len(d.switches))
+}
+
+func TestDecodeLevelBadChecksum(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	code, _ := EncodeLevel(l)
+
+	b := []byte(code)
+	if b[4] == 'A' {
+		b[4] = 'B'
+	} else {
+		b[4] = 'A'
+	}
+	_, err := DecodeLevel(string(b))
+
+	assert.NotNil(t, err)
+}
+
+func TestEncodeInvalidLevel(t *testing.T) {
+	l := ParseLevel(`01
+24
+
+0,0
+
+00
+24
+
+10`)
+
+	_, err := EncodeLevel(l)
+
+	assert.NotNil(t, err)
+}
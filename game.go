@@ -2,10 +2,12 @@ package main
 
 import (
 	"golang.org/x/mobile/event/size"
+	"golang.org/x/mobile/exp/sprite/clock"
 	"golang.org/x/mobile/gl"
 	_ "image/png"
 	"log"
 	"math"
+	"time"
 )
 
 const (
@@ -54,15 +56,117 @@ var (
 )
 
 type Game struct {
+	// currentLevel is 0 when playing a custom level
 	currentLevel int
-	level        Level
-	listen       bool
-	world        *World
+	level        *Level
+	state        StateMachine
+	// queue holds the player input received
+	// during a move, played once it's done.
+	queue     []ReplayEvent
+	queueSize int
+	// fastQueue speeds up the queued moves
+	fastQueue bool
+	// keyboard is true once a key is pressed,
+	// the switches display their key.
+	keyboard bool
+	// drag is the drag of the blocks in progress
+	drag *drag
+	// longPress is the touch held on a history button
+	longPress *longPress
+	// hover is the switch under the mouse
+	hover *Switch
+	// touching is true between the begin and the end of a touch,
+	// the moves outside of a touch are from a mouse.
+	touching bool
+	// shift is true while a shift key is held
+	shift bool
+	// losePolicy decides where the player
+	// goes on after a loss.
+	losePolicy LosePolicy
+	world      *World
+	// timer is the countdown of the time-attack
+	// mode, nil in the normal mode.
+	timer *Timer
+	// store holds the player progress
+	store *Store
+	// code is the level code of the custom level
+	code string
+	// prompt is the code prompt opened from the
+	// level select, nil when it's closed.
+	prompt *codePrompt
+	// now is the time of the last frame
+	now clock.Time
+	// replay records the player actions of the level,
+	// replayStart is the level start time.
+	replay      *Replay
+	replayStart clock.Time
+	// recordPath is the file where the replays are saved, one
+	// per level, empty if the replays are not saved.
+	recordPath string
+	// playback is the replay played instead
+	// of the player actions.
+	playback      *Replay
+	playbackNext  int
+	playbackSpeed float32
+	// sched gives the frame time.
+	sched *Scheduler
 }
 
-func NewGame(glctx gl.Context) {
-	g = &Game{currentLevel: 1}
+// NewGame starts the game with the saved data of the store.
+func NewGame(glctx gl.Context, store *Store) {
+	g = &Game{
+		store:     store,
+		sched:     NewScheduler(wallClock{start: time.Now()}),
+		queueSize: DefaultQueueSize,
+	}
+	g.losePolicy = g.store.Settings.losePolicy()
+	g.currentLevel = g.lastLevel()
+	g.level = LoadLevel(g.currentLevel)
+	g.restoreSnapshot()
+	g.levelLoaded()
+	// The app opens on the level select
+	g.OpenMenu()
+}
+
+// openStore returns the store of the app data directory,
+// or a store in memory if the directory can't be used.
+func openStore() *Store {
+	storage, err := NewFileStorage()
+	if err == nil {
+		var s *Store
+		if s, err = NewStore(storage); err == nil {
+			return s
+		}
+	}
+	log.Println("Can't open the saved data, the progress won't be saved", err)
+	s, _ := NewStore(&memStorage{})
+	return s
+}
+
+// lastLevel returns the highest level unlocked
+// by the player.
+func (g *Game) lastLevel() int {
+	level := g.store.Progress().Unlocked
+	for level > 1 && !hasLevel(level) {
+		// All the levels are solved
+		level--
+	}
+	return level
+}
+
+// SelectSlot changes the save slot and plays its last level.
+func (g *Game) SelectSlot(slot int) error {
+	if err := g.store.SelectSlot(slot); err != nil {
+		return err
+	}
+	g.currentLevel = g.lastLevel()
 	g.level = LoadLevel(g.currentLevel)
+	g.restoreSnapshot()
+	g.levelLoaded()
+	if g.world != nil {
+		g.world.LoadScene()
+	}
+	return nil
 }
 
 func initWorld(glctx gl.Context) {
@@ -124,62 +228,245 @@ func computeSizes(sz size.Event) {
 	levelTxtHeight = compute(LevelTxtHeight, minFactor)
 }
 
+// Stop is called when the app is not visible anymore,
+// and may be killed.
 func (g *Game) Stop() {
+	g.Pause()
+	g.SaveSnapshot()
+	g.saveReplay()
 }
 
 func (g *Game) Click(x, y float32) {
-	if g.Listen() {
-		switch {
-		case g.level.Win():
+	if g.playback != nil {
+		// The replay plays instead of the player
+		return
+	}
+	switch g.state.State() {
+	case Won:
+		if g.state.Ready(g.now) {
 			// Next level
 			g.Warp()
+		}
 
-		case g.level.Loose():
-			// Loose, restart
-			g.currentLevel = 1
-			g.level = LoadLevel(g.currentLevel)
-			g.world.LoadScene()
+	case Lost:
+		if g.state.Ready(g.now) {
+			g.StartOver()
+		}
 
+	case Menu:
+		switch {
+		case g.prompt != nil:
+			g.clickPrompt(x, y)
+
+		case g.world.codeButton.hit(x, y):
+			g.OpenPrompt()
+
+		case g.world.timeButton.hit(x, y):
+			g.ToggleTimeAttack()
+
+		default:
+			if level := g.world.menuLevel(x, y); level > 0 {
+				g.SelectLevel(level)
+			}
+		}
+
+	case Playing, Rotating:
+		switch {
 		case x < 30 && y < 30:
 			// Trick to warp level
 			// FIXME remove me
 			g.Warp()
 
-		case x > windowWidth-30 && y < 30:
-			// Trick to undo moves
-			// FIXME remove me
-			g.level.UndoLastMove()
+		case g.world.undoButton.hit(x, y):
+			g.Undo()
+
+		case g.world.redoButton.hit(x, y):
+			g.Redo()
+
+		case g.world.restartButton.hit(x, y):
+			g.Restart()
+
+		case g.world.menuButton.hit(x, y):
+			g.OpenMenu()
 
 		default:
-			g.level.PressSwitch(x, y)
+			if i, clockwise, ok := g.level.pressed(x, y); ok {
+				g.Press(i, clockwise)
+			}
 		}
 	}
 }
 
-func (g *Game) Listen() bool {
-	return g.listen && g.level.rotating == nil
+// Restart plays the current level again from the start.
+func (g *Game) Restart() {
+	if g.currentLevel == 0 {
+		l, err := DecodeLevel(g.code)
+		if err != nil {
+			log.Println("Can't restart the custom level", err)
+			return
+		}
+		g.level = l
+	} else {
+		g.level = LoadLevel(g.currentLevel)
+	}
+	g.levelLoaded()
+	g.world.LoadScene()
+}
+
+// levelLoaded restarts the state machine and
+// the replay record on a new level.
+func (g *Game) levelLoaded() {
+	g.queue = nil
+	g.hover = nil
+	g.state.Fire(OnLoad, g.now)
+	g.startReplay()
+}
+
+// updateState fires the state events according to
+// the level progress.
+func (g *Game) updateState() {
+	busy := g.level.rotating != nil || g.level.jumping
+	switch g.state.State() {
+	case Playing:
+		switch {
+		case busy:
+			g.state.Fire(OnMove, g.now)
+		case g.level.Win():
+			// Resumed on the win
+			g.queue = nil
+			g.state.Fire(OnWin, g.now)
+		case g.Lost():
+			// The time is over
+			g.state.Fire(OnLose, g.now)
+		}
+	case Rotating:
+		if busy {
+			return
+		}
+		switch {
+		case g.level.Win():
+			// The level is over, drop the queued actions
+			g.queue = nil
+			g.state.Fire(OnWin, g.now)
+		case g.Lost():
+			g.queue = nil
+			g.state.Fire(OnLose, g.now)
+		default:
+			g.state.Fire(OnMoveDone, g.now)
+			g.playQueued()
+		}
+	}
+}
+
+// Lost returns true if the player has no more moves,
+// or no more time in the time-attack mode.
+func (g *Game) Lost() bool {
+	if g.timer != nil && g.timer.Expired() && !g.level.Win() {
+		return true
+	}
+	return g.level.Loose()
+}
+
+// StartTimeAttack enables the time-attack mode.
+func (g *Game) StartTimeAttack() {
+	g.timer = NewTimer(TimeAttackSeconds)
+}
+
+// Tick continues the history jumps and the replays, updates
+// the game state and consumes the time-attack countdown while
+// the level is played.
+func (g *Game) Tick(now clock.Time) {
+	g.now = now
+	if state := g.state.State(); state == Paused || state == Menu {
+		return
+	}
+	g.stepLongPress()
+	g.level.stepJump()
+	g.stepPlayback()
+	g.updateState()
+	if g.timer == nil {
+		return
+	}
+	if g.level.Win() || g.Lost() {
+		// Don't count the time of the win and loose animations
+		g.timer.Pause()
+		return
+	}
+	g.timer.Resume()
+	g.timer.Tick(now)
+}
+
+// Pause ignores the input and stops the countdown,
+// while the app is not visible.
+func (g *Game) Pause() {
+	g.state.Fire(OnPause, g.now)
+	if g.timer != nil {
+		g.timer.Pause()
+	}
+}
+
+// Resume continues the game paused.
+func (g *Game) Resume() {
+	if g.state.State() == Paused {
+		g.state.Fire(OnResume, g.now)
+	}
 }
 
 func (g *Game) Continue() {
-	if g.level.Win() {
+	if g.state.State() == Won && g.state.Ready(g.now) {
 		g.Warp()
 	}
 }
 
 func (g *Game) Warp() {
-	if g.Listen() {
-		// Next level
-		g.currentLevel++
-		g.level = LoadLevel(g.currentLevel)
-		//FIXME clean resources
+	if g.level.Win() {
+		g.recordScore()
+		if g.timer != nil {
+			g.timer.Add(TimeBonusSeconds + g.level.RemainMoves())
+		}
+	}
+	// Next level
+	g.currentLevel++
+	g.level = LoadLevel(g.currentLevel)
+	g.levelLoaded()
+	//FIXME clean resources
+	g.world.LoadScene()
+}
+
+// recordScore saves the score of the current level
+// if it beats the previous ones.
+func (g *Game) recordScore() {
+	if g.currentLevel == 0 {
+		// Custom levels have no record
+		return
+	}
+	// The level is over, nothing to resume
+	g.store.Progress().Snapshot = nil
+	if err := g.store.Record(g.currentLevel, g.level.Score()); err != nil {
+		log.Println("Can't save the progress", err)
+	}
+}
+
+// EnterCode loads the custom level shared by a level code.
+func (g *Game) EnterCode(code string) error {
+	l, err := DecodeLevel(code)
+	if err != nil {
+		return err
+	}
+	g.currentLevel = 0
+	g.code = code
+	g.level = l
+	g.levelLoaded()
+	if g.world != nil {
 		g.world.LoadScene()
 	}
+	log.Println("Custom level loaded", code)
+	return nil
 }
 
 func (g *Game) Reset() {
 	sw := g.level.PopLastRotated()
 	if sw != nil {
-		g.listen = false
 		// TODO
 		//sw.ChangeState(NewResetState())
 	}
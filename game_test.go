@@ -5,8 +5,11 @@ import (
 	"testing"
 )
 
+// setup starts a game with the saved data in memory,
+// the tests don't touch the player data.
 func setup() {
-	NewGame()
+	store, _ := NewStore(&memStorage{})
+	NewGame(nil, store)
 }
 
 func fill() {
@@ -71,3 +74,359 @@ func TestBlockSignature(t *testing.T) {
 `
 	assert.Equal(t, signature, l.blockSignature())
 }
+
+func TestRotateSwitchDirections(t *testing.T) {
+	l := ParseLevel(`01
+24
+
+0,0,ccw
+
+10
+42
+
+10`)
+	sw := l.switches[0]
+
+	assert.Equal(t, CounterClockwise, sw.dir)
+	l.RotateSwitch(sw, false)
+	assert.Equal(t, "14\n02\n", l.blockSignature())
+	assert.Equal(t, 1, l.moves)
+	l.RotateSwitchInverse(sw, false)
+	assert.Equal(t, "01\n24\n", l.blockSignature())
+	assert.Equal(t, 0, l.moves)
+	l.RotateSwitch(sw, true)
+	assert.Equal(t, "20\n41\n", l.blockSignature())
+}
+
+func TestRotateLargeSwitch(t *testing.T) {
+	l := ParseLevel(`012
+345
+678
+
+0,0,3x3
+
+301
+642
+785
+
+10`)
+	sw := l.switches[0]
+
+	assert.Equal(t, 8, len(l.Blocks(sw)))
+	l.RotateSwitch(sw, true)
+	assert.Equal(t, "301\n642\n785\n", l.blockSignature())
+	assert.True(t, l.Win())
+	l.RotateSwitchInverse(sw, true)
+	assert.Equal(t, "012\n345\n678\n", l.blockSignature())
+}
+
+func TestPivot(t *testing.T) {
+	// A quarter turn from the top left to the top right
+	// of a 2x2 switch turns around the switch center.
+	x, y := pivot(1, 1, 3, 1, HalfPi)
+
+	assert.InDelta(t, 2, x, 1e-4)
+	assert.InDelta(t, 2, y, 1e-4)
+}
+
+func TestLockedBlocks(t *testing.T) {
+	l := ParseLevel(`0*12
+345
+
+0,0
+0,1
+
+032
+415
+
+10`)
+	sw := l.switches[0]
+
+	assert.Nil(t, l.Validate())
+	assert.True(t, l.blocks[0][0].locked)
+	assert.Equal(t, 3, len(l.blocks[0]))
+	assert.Equal(t, 3, len(l.Blocks(sw)))
+	l.RotateSwitch(sw, true)
+	assert.Equal(t, "032\n415\n", l.blockSignature())
+	assert.True(t, l.Win())
+}
+
+func TestLockedBlocksInconsistentWin(t *testing.T) {
+	l := ParseLevel(`0*1
+23
+
+0,0
+
+10
+23
+
+10`)
+
+	assert.NotNil(t, l.Validate())
+}
+
+func TestLimitedUses(t *testing.T) {
+	l := ParseLevel(`01
+24
+
+0,0,2
+
+20
+41
+
+10`)
+	sw := l.switches[0]
+
+	assert.Equal(t, 2, sw.uses)
+	l.triggerSwitch(0, true)
+	l.applyRotating()
+	l.triggerSwitch(0, true)
+	l.applyRotating()
+	assert.True(t, sw.exhausted())
+	assert.False(t, l.enabled(sw))
+	l.PopLastRotated()
+	assert.Equal(t, 1, sw.remaining)
+	assert.True(t, l.enabled(sw))
+}
+
+func TestLinkedSwitches(t *testing.T) {
+	l := ParseLevel(`0123
+4567
+
+0,0,-1
+0,2
+
+4051
+7362
+
+10`)
+
+	assert.Nil(t, l.Validate())
+	l.triggerSwitch(0, true)
+	assert.Equal(t, 2, len(l.rotating))
+	l.applyRotating()
+	assert.Equal(t, "4037\n5126\n", l.blockSignature())
+	assert.Equal(t, 1, l.moves)
+	l.rotating = l.PopLastRotated()
+	l.undoing = true
+	l.applyRotating()
+	assert.Equal(t, "0123\n4567\n", l.blockSignature())
+	assert.Equal(t, 0, l.moves)
+}
+
+func TestLinkedSwitchesOverlap(t *testing.T) {
+	l := ParseLevel(`012
+345
+
+0,0,+1
+0,1
+
+012
+345
+
+10`)
+
+	assert.NotNil(t, l.Validate())
+}
+
+func TestWrappedSwitch(t *testing.T) {
+	l := ParseLevel(`wrap
+012
+345
+
+1,2
+
+310
+542
+
+10`)
+	sw := l.switches[0]
+	bottom, right := l.wraps(sw)
+
+	assert.Nil(t, l.Validate())
+	assert.True(t, bottom)
+	assert.True(t, right)
+	l.RotateSwitch(sw, true)
+	assert.Equal(t, "310\n542\n", l.blockSignature())
+}
+
+func TestLinkedWrappedSwitch(t *testing.T) {
+	game := newStepGame(ParseLevel(`wrap
+0123
+4567
+89AB
+
+0,1,+1
+1,3
+
+3210
+7654
+BA98
+
+10`))
+	start := game.level.blockSignature()
+	expected := game.level.Copy()
+	expected.RotateSwitch(expected.switches[0], true)
+	expected.RotateSwitch(expected.switches[1], true)
+
+	game.frame(func() { game.Press(0, true) })
+	for i := 1; i < rotateDuration; i++ {
+		game.frame(nil)
+		// The flipped blocks don't apply the move before the turned ones
+		assert.NotNil(t, game.level.rotating, "tick %d", i)
+		assert.Equal(t, start, game.level.blockSignature(), "tick %d", i)
+	}
+	game.frame(nil)
+
+	assert.Nil(t, game.level.rotating)
+	assert.Equal(t, expected.blockSignature(), game.level.blockSignature())
+}
+
+func TestWrappedSwitchNotAllowed(t *testing.T) {
+	l := ParseLevel(`012
+345
+
+1,2
+
+310
+542
+
+10`)
+
+	assert.NotNil(t, l.Validate())
+}
+
+func TestShiftSwitches(t *testing.T) {
+	l := ParseLevel(`012
+345
+
+row,0
+col,2,ccw
+
+201
+354
+
+10`)
+
+	assert.Nil(t, l.Validate())
+	assert.Equal(t, "row,0", l.switches[0].format())
+	assert.Equal(t, "col,2,ccw", l.switches[1].format())
+	l.RotateSwitch(l.switches[0], true)
+	assert.Equal(t, "201\n345\n", l.blockSignature())
+	l.RotateSwitch(l.switches[1], false)
+	assert.Equal(t, "205\n341\n", l.blockSignature())
+}
+
+func TestShiftSlideWraps(t *testing.T) {
+	game := newDragGame(t, ParseLevel(`012
+345
+
+row,0
+
+201
+354
+
+10`))
+	wrapped := game.level.blocks[0][2]
+
+	game.frame(func() { game.Press(0, true) })
+	for i := 0; i < rotateDuration/4; i++ {
+		game.frame(nil)
+	}
+	// Shrinks out of the right edge
+	assert.True(t, wrapped.Tx > 0)
+	assert.True(t, wrapped.Scale < 1)
+	// The other blocks only slide
+	assert.Equal(t, float32(0), game.level.blocks[0][0].Sx)
+	for i := 0; i < rotateDuration/2; i++ {
+		game.frame(nil)
+	}
+	// Grows back from the left edge
+	assert.True(t, wrapped.X+wrapped.Tx < game.level.blocks[0][0].X)
+	assert.True(t, wrapped.Scale > 0)
+	game.settle()
+	assert.Equal(t, "201\n345\n", game.level.blockSignature())
+}
+
+func TestHexLevel(t *testing.T) {
+	l := ParseLevel(`hex
+0011
+0011
+2233
+2233
+
+0,0
+1,1
+2,2,up
+
+0011
+0011
+2233
+2233
+
+10`)
+
+	assert.Nil(t, l.Validate())
+	assert.True(t, l.hex)
+	assert.Equal(t, []cell{{1, 1}, {1, 2}, {2, 2}}, l.cells(l.switches[1]))
+	assert.Equal(t, []cell{{2, 2}, {3, 2}, {3, 1}}, l.cells(l.switches[2]))
+	assert.Equal(t, "2,2,up", l.switches[2].format())
+	l.RotateSwitch(l.switches[1], true)
+	assert.Equal(t, "0011\n0301\n2213\n2233\n", l.blockSignature())
+}
+
+func TestHexSwitchOutOfBoard(t *testing.T) {
+	l := ParseLevel(`hex
+01
+23
+
+1,0
+
+01
+23
+
+10`)
+
+	assert.NotNil(t, l.Validate())
+}
+
+func TestPartialWin(t *testing.T) {
+	l := ParseLevel(`0123
+4567
+0123
+4567
+
+1,1
+
+....
+.15.
+.26.
+....
+
+10`)
+
+	assert.Nil(t, l.Validate())
+	assert.False(t, l.Win())
+	l.RotateSwitch(l.switches[0], true)
+	assert.True(t, l.Win())
+
+	code, err := EncodeLevel(l)
+	assert.Nil(t, err)
+	d, err := DecodeLevel(code)
+	assert.Nil(t, err)
+	assert.Equal(t, l.winSignature, d.winSignature)
+}
+
+func TestPartialWinMissingColor(t *testing.T) {
+	l := ParseLevel(`0123
+4567
+
+0,0
+
+..33
+....
+
+10`)
+
+	assert.NotNil(t, l.Validate())
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"math"
+
+	"golang.org/x/mobile/exp/sprite/clock"
+)
+
+const (
+	// dragDistance is the distance the finger moves
+	// before a touch becomes a drag.
+	dragDistance = 2 * touchDelta
+	// swipeCommit is the part of the rotation to drag
+	// for the move to be played once released.
+	swipeCommit = .25
+	// longPressDelay is the time a finger stays on the undo
+	// or redo button to jump to the start or the end of the
+	// history.
+	longPressDelay = FPS / 2
+)
+
+// longPress is a touch held on a history button.
+type longPress struct {
+	button *Button
+	since  clock.Time
+	// done is true once the jump is made
+	done bool
+}
+
+// drag is a circular drag of the blocks around a switch.
+type drag struct {
+	sw int
+	// cx, cy is the switch center
+	cx, cy float32
+	// x0, y0 is where the touch started
+	x0, y0 float32
+	// last is the direction of the finger from the switch
+	// center, swept is the angle covered since the start.
+	last, swept float32
+	// angle is the rotation of the blocks, limited
+	// to one move in the allowed directions.
+	angle float32
+	step  float32
+	// clockwise is the direction of the block pivots
+	clockwise bool
+	dragging  bool
+}
+
+// part returns the part of the move dragged.
+func (d *drag) part() float32 {
+	return float32(math.Abs(float64(d.angle / d.step)))
+}
+
+// dragSwitch returns the switch whose blocks can be dragged
+// from the coordinates. The touches on the switch itself
+// are taps.
+func (l *Level) dragSwitch(x, y float32) (int, *Switch) {
+	var found *Switch
+	index, nearest := -1, float32(math.MaxFloat32)
+	for i, s := range l.switches {
+		if s.shift() || s.hex() || !l.enabled(s) {
+			continue
+		}
+		if bottom, right := l.wraps(s); bottom || right {
+			continue
+		}
+		size := s.lines
+		if s.cols > size {
+			size = s.cols
+		}
+		half := (blockSize + blockPadding*2) * float32(size) / 2
+		dx, dy := x-s.X-switchSize/2, y-s.Y-switchSize/2
+		if dx < -half || dx > half || dy < -half || dy > half {
+			continue
+		}
+		d := dx*dx + dy*dy
+		if d > switchSize*switchSize/4 && d < nearest {
+			found, index, nearest = s, i, d
+		}
+	}
+	return index, found
+}
+
+func angleTo(x, y, cx, cy float32) float32 {
+	return float32(math.Atan2(float64(y-cy), float64(x-cx)))
+}
+
+// TouchBegin starts a drag if the finger is
+// on the blocks around a switch.
+func (g *Game) TouchBegin(x, y float32) {
+	g.touching = true
+	g.drag = nil
+	g.longPress = nil
+	if g.world != nil {
+		for _, b := range []*Button{g.world.undoButton, g.world.redoButton} {
+			if b.hit(x, y) {
+				g.longPress = &longPress{button: b, since: g.now}
+				return
+			}
+		}
+	}
+	if g.playback != nil || g.state.State() != Playing || g.level.rotating != nil {
+		return
+	}
+	i, s := g.level.dragSwitch(x, y)
+	if s == nil {
+		return
+	}
+	cx, cy := s.X+switchSize/2, s.Y+switchSize/2
+	g.drag = &drag{
+		sw: i,
+		cx: cx, cy: cy,
+		x0: x, y0: y,
+		last: angleTo(x, y, cx, cy),
+		step: TwoPi / float32(len(g.level.Blocks(s))),
+	}
+}
+
+// TouchMove turns the dragged blocks with the finger.
+// The moves without touch hover the switches.
+func (g *Game) TouchMove(x, y float32) {
+	if !g.touching {
+		g.Hover(x, y)
+		return
+	}
+	d := g.drag
+	if d == nil {
+		return
+	}
+	if g.state.State() != Playing || g.level.rotating != nil {
+		// Interrupted by an other move
+		g.drag = nil
+		return
+	}
+	a := angleTo(x, y, d.cx, d.cy)
+	delta := a - d.last
+	// Keep the shortest way when crossing the atan2 bounds
+	if delta > math.Pi {
+		delta -= TwoPi
+	} else if delta < -math.Pi {
+		delta += TwoPi
+	}
+	d.last = a
+	d.swept += delta
+	s := g.level.switches[d.sw]
+	if !d.dragging {
+		if dx, dy := x-d.x0, y-d.y0; dx*dx+dy*dy < dragDistance*dragDistance {
+			return
+		}
+		d.dragging = true
+		d.clockwise = d.swept > 0
+		for _, b := range g.level.Blocks(s) {
+			b.Reset()
+			b.Action = ActionFunc(blockFollow)
+		}
+		pivotBlocks(g.level.Blocks(s), d.clockwise)
+	}
+	d.angle = d.swept
+	if d.angle > d.step {
+		d.angle = d.step
+	} else if d.angle < -d.step {
+		d.angle = -d.step
+	}
+	if (d.angle > 0 && s.dir == CounterClockwise) || (d.angle < 0 && s.dir == Clockwise) {
+		// The switch can't turn this way
+		d.angle = 0
+	}
+	if d.angle != 0 && d.angle > 0 != d.clockwise {
+		// The finger changed the direction
+		d.clockwise = !d.clockwise
+		pivotBlocks(g.level.Blocks(s), d.clockwise)
+	}
+}
+
+// TouchEnd plays the move in the direction of the drag if the
+// blocks turned enough, otherwise they snap back. A touch which
+// isn't a drag is a click, counter clockwise with shift held.
+func (g *Game) TouchEnd(x, y float32) {
+	g.touching = false
+	if p := g.longPress; p != nil {
+		g.longPress = nil
+		if p.done {
+			// The jump replaces the click
+			return
+		}
+	}
+	d := g.drag
+	g.drag = nil
+	if d == nil || !d.dragging {
+		if g.shift {
+			g.ShiftClick(x, y)
+			return
+		}
+		g.Click(x, y)
+		return
+	}
+	if d.part() < swipeCommit || g.state.State() != Playing {
+		return
+	}
+	g.level.dragged = d.part()
+	g.Press(d.sw, d.clockwise)
+	g.level.dragged = 0
+}
+
+// stepLongPress jumps to the start of the history when the
+// undo button is held, and to its end for the redo button.
+func (g *Game) stepLongPress() {
+	p := g.longPress
+	if p == nil || p.done || g.now-p.since < longPressDelay {
+		return
+	}
+	p.done = true
+	if p.button == g.world.undoButton {
+		g.JumpTo(0)
+	} else {
+		g.JumpTo(g.level.HistoryLen())
+	}
+}
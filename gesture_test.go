@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mobile/event/size"
+)
+
+// newDragGame returns a game laid out on a portrait window.
+func newDragGame(t *testing.T, l *Level) *stepGame {
+	computeSizes(size.Event{WidthPt: PortraitWidth, HeightPt: PortraitHeight})
+	t.Cleanup(func() { computeSizes(size.Event{}) })
+	return newStepGame(l)
+}
+
+// dragAround drags the finger around the switch center, from
+// the angle from to the angle to, in a few moves.
+func dragAround(game *stepGame, sw int, from, to float64) {
+	s := game.level.switches[sw]
+	cx, cy := s.X+switchSize/2, s.Y+switchSize/2
+	r := float64(blockSize) * .7
+	at := func(a float64) (float32, float32) {
+		return cx + float32(r*math.Cos(a)), cy + float32(r*math.Sin(a))
+	}
+	game.TouchBegin(at(from))
+	for i := 1; i <= 4; i++ {
+		game.TouchMove(at(from + (to-from)*float64(i)/4))
+		game.frame(nil)
+	}
+	game.TouchEnd(at(to))
+}
+
+func TestDragClockwise(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	expected := l.Copy()
+	expected.RotateSwitch(expected.switches[0], true)
+	game := newDragGame(t, l)
+
+	// From the top left block to the top right one
+	dragAround(game, 0, -3*math.Pi/4, -math.Pi/4)
+	game.settle()
+
+	assert.Equal(t, 1, game.level.moves)
+	assert.Equal(t, Move{{sw: 0, clockwise: true}}, game.level.rotated[0])
+	assert.Equal(t, expected.blockSignature(), game.level.blockSignature())
+}
+
+func TestDragFollowsFinger(t *testing.T) {
+	game := newDragGame(t, ParseLevel(codeLevel))
+	b := game.level.blocks[1][1]
+
+	// From the bottom right block towards the bottom left one
+	game.TouchBegin(b.X+b.Width/2, b.Y+b.Height/2)
+	game.TouchMove(b.X, b.Y+b.Height)
+	game.frame(nil)
+
+	assert.True(t, game.drag.dragging)
+	assert.True(t, b.Angle > 0)
+	assert.Equal(t, game.drag.angle, b.Angle)
+}
+
+func TestDragSnapBack(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	initial := l.blockSignature()
+	game := newDragGame(t, l)
+
+	dragAround(game, 0, -3*math.Pi/4, -3*math.Pi/4+.2)
+	for i := 0; i < 10; i++ {
+		game.frame(nil)
+	}
+
+	assert.Equal(t, 0, game.level.moves)
+	assert.Nil(t, game.level.rotating)
+	assert.Equal(t, initial, game.level.blockSignature())
+	assert.Equal(t, float32(0), game.level.blocks[1][1].Angle)
+}
+
+func TestDragWrongDirection(t *testing.T) {
+	game := newDragGame(t, ParseLevel(codeLevel))
+
+	// The switch only turns clockwise
+	dragAround(game, 0, -math.Pi/4, -3*math.Pi/4)
+	game.settle()
+
+	assert.Equal(t, 0, game.level.moves)
+}
+
+func TestDragCounterClockwise(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	l.switches[0].dir = BothWays
+	expected := l.Copy()
+	expected.RotateSwitch(expected.switches[0], false)
+	game := newDragGame(t, l)
+
+	dragAround(game, 0, -math.Pi/4, -3*math.Pi/4)
+	game.settle()
+
+	assert.Equal(t, Move{{sw: 0, clockwise: false}}, game.level.rotated[0])
+	assert.Equal(t, expected.blockSignature(), game.level.blockSignature())
+}
+
+func TestTapIsClick(t *testing.T) {
+	game := newDragGame(t, ParseLevel(codeLevel))
+	s := game.level.switches[0]
+
+	game.TouchBegin(s.X+switchSize/2, s.Y+switchSize/2)
+	game.TouchEnd(s.X+switchSize/2, s.Y+switchSize/2)
+
+	assert.Nil(t, game.drag)
+	assert.Equal(t, Move{{sw: 0, clockwise: true}}, game.level.rotating)
+}
@@ -0,0 +1,147 @@
+package main
+
+// Goal represents the condition to win a level.
+type Goal interface {
+	// Reached returns true if the block colors fulfill the goal.
+	Reached(colors [][]Color) bool
+	// Distance estimates how far the block colors are from the
+	// goal, the resolver explores the closest boards first.
+	Distance(colors [][]Color) int
+}
+
+// Pattern goals are written instead of the win blocks
+// in the level files.
+const (
+	GoalDistinctRows = "rows"
+	GoalNoAdjacent   = "noadjacent"
+)
+
+// parseGoal returns the pattern goal of the level file line,
+// or nil if the line is not a pattern goal.
+func parseGoal(line string, hex bool) Goal {
+	switch line {
+	case GoalDistinctRows:
+		return distinctRowsGoal{}
+	case GoalNoAdjacent:
+		return noAdjacentGoal{hex: hex}
+	}
+	return nil
+}
+
+// signatureGoal is reached when the blocks match the
+// win signature, except on the Ignored cells.
+type signatureGoal struct {
+	signature [][]Color
+	hex       bool
+}
+
+func (s signatureGoal) Reached(colors [][]Color) bool {
+	for i := range s.signature {
+		for j := range s.signature[i] {
+			if c := s.signature[i][j]; c != Ignored && c != colors[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Distance sums, for each misplaced block, the distance
+// to the farthest win position of its color.
+func (s signatureGoal) Distance(colors [][]Color) int {
+	howfar := 0
+	for i := range s.signature {
+		for j := range s.signature[i] {
+			if c := s.signature[i][j]; c != Ignored && c != colors[i][j] {
+				howfar += s.farthest(colors[i][j], i, j)
+			}
+		}
+	}
+	return howfar
+}
+
+func (s signatureGoal) farthest(c Color, x, y int) int {
+	max := 0
+	for i := range s.signature {
+		for j := range s.signature[i] {
+			if c == s.signature[i][j] {
+				var d int
+				if s.hex {
+					d = hexDistance(x, y, i, j)
+				} else {
+					d = manhattan(x, y, i, j)
+				}
+				if d > max {
+					max = d
+				}
+			}
+		}
+	}
+	return max
+}
+
+// distinctRowsGoal is reached when every row contains
+// one block of each color.
+type distinctRowsGoal struct{}
+
+func (distinctRowsGoal) Reached(colors [][]Color) bool {
+	return distinctRowsGoal{}.Distance(colors) == 0
+}
+
+// Distance counts the duplicated colors in the rows.
+func (distinctRowsGoal) Distance(colors [][]Color) int {
+	dups := 0
+	for i := range colors {
+		seen := make(map[Color]bool)
+		for _, c := range colors[i] {
+			if c == Empty {
+				continue
+			}
+			if seen[c] {
+				dups++
+			}
+			seen[c] = true
+		}
+	}
+	return dups
+}
+
+// noAdjacentGoal is reached when no neighbour blocks
+// share the same color.
+type noAdjacentGoal struct {
+	hex bool
+}
+
+func (g noAdjacentGoal) Reached(colors [][]Color) bool {
+	return g.Distance(colors) == 0
+}
+
+// Distance counts the neighbours sharing the same color.
+func (g noAdjacentGoal) Distance(colors [][]Color) int {
+	pairs := 0
+	for i := range colors {
+		for j := range colors[i] {
+			if colors[i][j] == Empty {
+				continue
+			}
+			for _, n := range g.neighbours(i, j) {
+				if n.line < len(colors) && n.col >= 0 && n.col < len(colors[n.line]) &&
+					colors[n.line][n.col] == colors[i][j] {
+					pairs++
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+// neighbours returns the right and bottom neighbours of the
+// block, so each pair of blocks is checked once.
+func (g noAdjacentGoal) neighbours(line, col int) []cell {
+	if !g.hex {
+		return []cell{{line, col + 1}, {line + 1, col}}
+	}
+	// The odd lines are shifted to the right
+	odd := line % 2
+	return []cell{{line, col + 1}, {line + 1, col + odd - 1}, {line + 1, col + odd}}
+}
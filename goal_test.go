@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistinctRowsGoal(t *testing.T) {
+	goal := distinctRowsGoal{}
+
+	assert.True(t, goal.Reached([][]Color{{'0', '1'}, {'1', '0'}}))
+	assert.False(t, goal.Reached([][]Color{{'0', '0'}, {'1', '1'}}))
+	assert.Equal(t, 2, goal.Distance([][]Color{{'0', '0'}, {'1', '1'}}))
+}
+
+func TestNoAdjacentGoal(t *testing.T) {
+	goal := noAdjacentGoal{}
+
+	assert.True(t, goal.Reached([][]Color{{'0', '1'}, {'1', '0'}}))
+	assert.False(t, goal.Reached([][]Color{{'0', '1'}, {'0', '1'}}))
+	assert.Equal(t, 2, goal.Distance([][]Color{{'0', '1'}, {'0', '1'}}))
+}
+
+func TestNoAdjacentGoalHex(t *testing.T) {
+	goal := noAdjacentGoal{hex: true}
+
+	// The line 1 is shifted, so the blocks 0,1 and 1,0 are neighbours
+	assert.False(t, goal.Reached([][]Color{{'0', '1'}, {'1', '0'}}))
+	assert.True(t, goal.Reached([][]Color{{'0', '1'}, {'2', '0'}}))
+}
+
+func TestPatternGoalLevel(t *testing.T) {
+	l := ParseLevel(`0011
+2233
+0011
+2233
+
+0,0
+0,2
+2,0
+2,2
+
+rows
+
+10`)
+
+	assert.Nil(t, l.Validate())
+	assert.False(t, l.Win())
+	l.RotateSwitch(l.switches[0], true)
+	assert.Equal(t, "2011\n2033\n0011\n2233\n", l.blockSignature())
+	assert.False(t, l.Win())
+
+	n := Resolve(l)
+
+	assert.NotNil(t, n)
+}
+
+func TestPatternGoalInvalidColors(t *testing.T) {
+	l := ParseLevel(`0011
+0011
+
+0,0
+
+rows
+
+10`)
+
+	assert.NotNil(t, l.Validate())
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"golang.org/x/mobile/exp/sprite/clock"
+)
+
+// jumpDuration is the rotation duration when several
+// moves are replayed or rewound in a row.
+const jumpDuration = 5
+
+// Redo replays the last undone move.
+func (l *Level) Redo() {
+	if l.rotating != nil {
+		return
+	}
+	l.redo(l.duration(rotateDuration))
+}
+
+func (l *Level) redo(duration clock.Time) {
+	n := len(l.undone)
+	if n == 0 {
+		return
+	}
+	m := l.undone[n-1]
+	l.undone = l.undone[:n-1]
+	l.play(m, duration)
+}
+
+// HistoryLen returns the number of moves which can be
+// reached by JumpTo, undone moves included.
+func (l *Level) HistoryLen() int {
+	return len(l.rotated) + len(l.undone)
+}
+
+// JumpTo rewinds or replays the history until the move
+// count reaches the move in parameter. The moves are
+// played one after the other by stepJump.
+func (l *Level) JumpTo(move int) {
+	if move < 0 || move > l.HistoryLen() {
+		return
+	}
+	l.jumping = true
+	l.jump = move
+}
+
+// stepJump starts the next move of the jump, once the
+// previous one is done.
+func (l *Level) stepJump() {
+	if !l.jumping || l.rotating != nil {
+		return
+	}
+	switch pos := len(l.rotated); {
+	case pos > l.jump:
+		l.undo(jumpDuration)
+	case pos < l.jump:
+		l.redo(jumpDuration)
+	default:
+		l.jumping = false
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUndoRedo(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	initial := l.blockSignature()
+	l.triggerSwitch(2, true)
+	l.applyRotating()
+	played := l.blockSignature()
+
+	l.UndoLastMove()
+	l.applyRotating()
+	assert.Equal(t, initial, l.blockSignature())
+	assert.Equal(t, 0, l.moves)
+	l.Redo()
+	l.applyRotating()
+
+	assert.Equal(t, played, l.blockSignature())
+	assert.Equal(t, 1, l.moves)
+	assert.Equal(t, 0, len(l.undone))
+}
+
+func TestNewMoveClearsRedo(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	l.triggerSwitch(2, true)
+	l.applyRotating()
+	l.UndoLastMove()
+	l.applyRotating()
+
+	l.triggerSwitch(1, true)
+	l.applyRotating()
+
+	assert.Equal(t, 0, len(l.undone))
+	assert.Equal(t, 1, l.HistoryLen())
+}
+
+func TestJumpTo(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	var signatures []string
+	for _, sw := range []int{0, 1, 2} {
+		signatures = append(signatures, l.blockSignature())
+		l.triggerSwitch(sw, true)
+		l.applyRotating()
+	}
+	signatures = append(signatures, l.blockSignature())
+
+	l.JumpTo(1)
+	for l.jumping {
+		l.stepJump()
+		l.applyRotating()
+	}
+	assert.Equal(t, signatures[1], l.blockSignature())
+	assert.Equal(t, 3, l.HistoryLen())
+
+	l.JumpTo(3)
+	for l.jumping {
+		l.stepJump()
+		l.applyRotating()
+	}
+	assert.Equal(t, signatures[3], l.blockSignature())
+	assert.Equal(t, 3, l.moves)
+}
+
+func TestLongPressJump(t *testing.T) {
+	game := newDragGame(t, ParseLevel(codeLevel))
+	game.startReplay()
+	for _, sw := range []int{2, 1} {
+		game.Press(sw, true)
+		game.settle()
+	}
+	b := game.world.undoButton
+	x, y := b.X+b.Width/2, b.Y+b.Height/2
+
+	game.TouchBegin(x, y)
+	for i := 0; i < longPressDelay; i++ {
+		game.frame(nil)
+	}
+	game.settle()
+	game.TouchEnd(x, y)
+	game.settle()
+
+	assert.Equal(t, 0, game.level.moves)
+	assert.Equal(t, 2, len(game.level.undone))
+	assert.Equal(t, ReplayEvent{Time: game.replay.Events[2].Time, Kind: EventJump}, game.replay.Events[2])
+}
+
+func TestShortPressUndo(t *testing.T) {
+	game := newDragGame(t, ParseLevel(codeLevel))
+	for _, sw := range []int{2, 1} {
+		game.Press(sw, true)
+		game.settle()
+	}
+	b := game.world.undoButton
+	x, y := b.X+b.Width/2, b.Y+b.Height/2
+
+	game.TouchBegin(x, y)
+	game.frame(nil)
+	game.TouchEnd(x, y)
+	game.settle()
+
+	assert.Equal(t, 1, game.level.moves)
+	assert.Equal(t, 1, len(game.level.undone))
+}
@@ -0,0 +1,80 @@
+package main
+
+const (
+	// DefaultQueueSize is the number of player
+	// actions queued during a move.
+	DefaultQueueSize = 3
+	// queuedDuration is the rotation duration of the
+	// queued moves, when they are played faster.
+	queuedDuration = 8
+)
+
+// playable returns true if the event can be applied
+// on the level.
+func (g *Game) playable(e ReplayEvent) bool {
+	switch e.Kind {
+	case EventPress:
+		return g.level.enabled(g.level.switches[e.Sw])
+	case EventUndo:
+		return len(g.level.rotated) > 0
+	case EventRedo:
+		return len(g.level.undone) > 0
+	case EventJump:
+		return e.Move >= 0 && e.Move <= g.level.HistoryLen() && e.Move != len(g.level.rotated)
+	}
+	return false
+}
+
+// input plays and records the player action. The actions
+// received during a move are queued.
+func (g *Game) input(e ReplayEvent) {
+	switch g.state.State() {
+	case Playing:
+		if g.playable(e) {
+			g.record(e)
+			g.apply(e)
+		}
+	case Rotating:
+		if n := len(g.queue); e.Kind == EventUndo && n > 0 && g.queue[n-1].Kind == EventPress {
+			// Cancel the press not played yet
+			g.queue = g.queue[:n-1]
+			return
+		}
+		if len(g.queue) < g.queueSize {
+			g.queue = append(g.queue, e)
+		}
+	}
+}
+
+// playQueued plays the actions queued during the move,
+// until one of them starts a new move. The actions which
+// can't be played anymore are dropped.
+func (g *Game) playQueued() {
+	g.level.hurry = g.fastQueue
+	defer func() { g.level.hurry = false }()
+	for len(g.queue) > 0 && g.state.State() == Playing {
+		e := g.queue[0]
+		g.queue = g.queue[1:]
+		g.input(e)
+	}
+}
+
+// Press presses the switch.
+func (g *Game) Press(i int, clockwise bool) {
+	g.input(ReplayEvent{Kind: EventPress, Sw: i, Clockwise: clockwise})
+}
+
+// Undo cancels the last move.
+func (g *Game) Undo() {
+	g.input(ReplayEvent{Kind: EventUndo})
+}
+
+// Redo replays the last undone move.
+func (g *Game) Redo() {
+	g.input(ReplayEvent{Kind: EventRedo})
+}
+
+// JumpTo rewinds or replays the history up to the move.
+func (g *Game) JumpTo(move int) {
+	g.input(ReplayEvent{Kind: EventJump, Move: move})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// settle plays the frames until the moves are done.
+func (s *stepGame) settle() {
+	for i := 0; i < 200 && (s.state.State() == Rotating || len(s.queue) > 0); i++ {
+		s.frame(nil)
+	}
+}
+
+func TestQueueLimit(t *testing.T) {
+	game := newStepGame(ParseLevel(codeLevel))
+
+	game.frame(func() {
+		for i := 0; i < DefaultQueueSize+3; i++ {
+			game.Press(0, true)
+		}
+	})
+	assert.Equal(t, DefaultQueueSize, len(game.queue))
+	game.settle()
+
+	assert.Equal(t, DefaultQueueSize+1, game.level.moves)
+	assert.Equal(t, DefaultQueueSize+1, len(game.level.rotated))
+}
+
+func TestQueueOrder(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	expected := l.Copy()
+	expected.RotateSwitch(expected.switches[2], true)
+	expected.RotateSwitch(expected.switches[0], false)
+	expected.RotateSwitch(expected.switches[1], true)
+	game := newStepGame(l)
+
+	game.frame(func() {
+		game.Press(2, true)
+		game.Press(0, false)
+		game.Press(1, true)
+	})
+	game.settle()
+
+	assert.Equal(t, expected.blockSignature(), game.level.blockSignature())
+	assert.Equal(t, Move{{sw: 0, clockwise: false}}, game.level.rotated[1])
+}
+
+func TestQueueUndoCancelsPress(t *testing.T) {
+	game := newStepGame(ParseLevel(codeLevel))
+
+	game.frame(func() {
+		game.Press(2, true)
+		game.Press(0, true)
+		game.Undo()
+	})
+	assert.Equal(t, 0, len(game.queue))
+	game.settle()
+
+	assert.Equal(t, 1, game.level.moves)
+	assert.Equal(t, Move{{sw: 2, clockwise: true}}, game.level.rotated[0])
+}
+
+func TestQueueUndo(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	initial := l.blockSignature()
+	game := newStepGame(l)
+
+	game.frame(func() {
+		game.Press(2, true)
+		game.Undo()
+	})
+	game.settle()
+
+	assert.Equal(t, 0, game.level.moves)
+	assert.Equal(t, initial, game.level.blockSignature())
+	assert.Equal(t, 1, len(game.level.undone))
+}
+
+func TestQueueFast(t *testing.T) {
+	game := newStepGame(ParseLevel(codeLevel))
+	game.fastQueue = true
+
+	game.frame(func() {
+		game.Press(2, true)
+		game.Press(0, true)
+	})
+	// The first move takes its time
+	for i := 0; i <= rotateDuration; i++ {
+		game.frame(nil)
+	}
+	assert.Equal(t, 2, len(game.level.rotated))
+	for i := 0; i < queuedDuration; i++ {
+		game.frame(nil)
+	}
+
+	assert.Nil(t, game.level.rotating)
+	assert.Equal(t, 2, game.level.moves)
+	assert.False(t, game.level.hurry)
+}
+
+func TestQueueDroppedOnWin(t *testing.T) {
+	game := newStepGame(ParseLevel(scoreLevel))
+
+	game.frame(func() {
+		game.Press(0, true)
+		game.Press(0, true)
+	})
+	game.settle()
+
+	assert.Equal(t, Won, game.state.State())
+	assert.Equal(t, 1, game.level.moves)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strconv"
+
+	"golang.org/x/mobile/event/key"
+)
+
+// keyName returns the name of the switch bound to the
+// digit key, empty if the key isn't a digit.
+func keyName(c key.Code) string {
+	switch {
+	case c >= key.Code1 && c <= key.Code9:
+		return strconv.Itoa(int(c-key.Code1) + 1)
+	case c >= key.CodeKeypad1 && c <= key.CodeKeypad9:
+		return strconv.Itoa(int(c-key.CodeKeypad1) + 1)
+	}
+	return ""
+}
+
+// Key handles the keyboard controls. The digits press the
+// switches as they are placed on the numpad.
+func (g *Game) Key(e key.Event) {
+	if e.Code == key.CodeLeftShift || e.Code == key.CodeRightShift {
+		// Held for the shift clicks
+		g.shift = e.Direction != key.DirRelease
+		return
+	}
+	if e.Direction != key.DirPress {
+		return
+	}
+	g.keyboard = true
+	if g.playback != nil {
+		// The replay plays instead of the player
+		return
+	}
+	state := g.state.State()
+	switch {
+	case state == Menu && g.prompt != nil:
+		g.keyPrompt(e)
+
+	case e.Code == key.CodeEscape && state == Menu:
+		g.CloseMenu()
+
+	case e.Code == key.CodeEscape:
+		if state == Paused {
+			g.Resume()
+		} else {
+			g.Pause()
+		}
+
+	case state == Intro || state == Paused || state == Menu:
+
+	case e.Code == key.CodeR && state == Lost:
+		if g.state.Ready(g.now) {
+			g.StartOver()
+		}
+
+	case e.Code == key.CodeR:
+		g.Restart()
+
+	case state == Won || state == Lost:
+		if e.Code == key.CodeN || e.Code == key.CodeReturnEnter || e.Code == key.CodeKeypadEnter {
+			if state == Won {
+				g.Continue()
+			} else if g.state.Ready(g.now) {
+				g.StartOver()
+			}
+		}
+
+	case e.Code == key.CodeDeleteBackspace,
+		e.Code == key.CodeZ && e.Modifiers&key.ModControl != 0:
+		g.Undo()
+
+	case e.Code == key.CodeHome:
+		g.JumpTo(0)
+
+	case e.Code == key.CodeEnd:
+		g.JumpTo(g.level.HistoryLen())
+
+	case keyName(e.Code) != "":
+		if i := g.level.switchNamed(keyName(e.Code)); i >= 0 {
+			g.Press(i, g.level.switches[i].directions()[0])
+		}
+	}
+}
+
+// keyPrompt types the level code in the code prompt.
+func (g *Game) keyPrompt(e key.Event) {
+	switch e.Code {
+	case key.CodeEscape:
+		g.ClosePrompt()
+
+	case key.CodeDeleteBackspace:
+		g.EraseCode()
+
+	case key.CodeReturnEnter, key.CodeKeypadEnter:
+		g.SubmitCode()
+
+	default:
+		if e.Rune > 0 && e.Rune < 128 {
+			g.TypeCode(byte(e.Rune))
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mobile/event/key"
+)
+
+func press(c key.Code, m key.Modifiers) key.Event {
+	return key.Event{Code: c, Modifiers: m, Direction: key.DirPress}
+}
+
+func TestKeyName(t *testing.T) {
+	assert.Equal(t, "1", keyName(key.Code1))
+	assert.Equal(t, "9", keyName(key.Code9))
+	assert.Equal(t, "7", keyName(key.CodeKeypad7))
+	assert.Equal(t, "", keyName(key.Code0))
+	assert.Equal(t, "", keyName(key.CodeA))
+}
+
+func TestKeyPressSwitch(t *testing.T) {
+	game := newStepGame(ParseLevel(codeLevel))
+
+	// The switch 2,2 is bound to the key 3
+	game.Key(press(key.CodeKeypad3, 0))
+
+	assert.True(t, game.keyboard)
+	assert.Equal(t, Move{{sw: 2, clockwise: true}}, game.level.rotating)
+}
+
+func TestKeyRelease(t *testing.T) {
+	game := newStepGame(ParseLevel(codeLevel))
+
+	game.Key(key.Event{Code: key.Code3, Direction: key.DirRelease})
+
+	assert.Nil(t, game.level.rotating)
+}
+
+func TestKeyUndo(t *testing.T) {
+	for _, e := range []key.Event{
+		press(key.CodeDeleteBackspace, 0),
+		press(key.CodeZ, key.ModControl),
+	} {
+		game := newStepGame(ParseLevel(codeLevel))
+		game.Key(press(key.Code3, 0))
+		game.settle()
+
+		game.Key(e)
+		game.settle()
+
+		assert.Equal(t, 0, game.level.moves, e.Code)
+		assert.Equal(t, 1, len(game.level.undone), e.Code)
+	}
+}
+
+func TestKeyJump(t *testing.T) {
+	game := newStepGame(ParseLevel(codeLevel))
+	for _, k := range []key.Code{key.Code3, key.Code4} {
+		game.Key(press(k, 0))
+		game.settle()
+	}
+
+	game.Key(press(key.CodeHome, 0))
+	game.settle()
+	assert.Equal(t, 0, game.level.moves)
+	assert.Equal(t, 2, len(game.level.undone))
+	game.Key(press(key.CodeEnd, 0))
+	game.settle()
+
+	assert.Equal(t, 2, game.level.moves)
+	assert.Equal(t, 0, len(game.level.undone))
+}
+
+func TestKeyPause(t *testing.T) {
+	game := newStepGame(ParseLevel(codeLevel))
+
+	game.Key(press(key.CodeEscape, 0))
+	assert.Equal(t, Paused, game.state.State())
+	game.Key(press(key.Code3, 0))
+	assert.Nil(t, game.level.rotating)
+	game.Key(press(key.CodeEscape, 0))
+
+	assert.Equal(t, Playing, game.state.State())
+}
+
+func TestKeyRestart(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	initial := l.blockSignature()
+	code, _ := EncodeLevel(l)
+	game := newStepGame(l)
+	game.code = code
+	game.Key(press(key.Code3, 0))
+	game.settle()
+
+	game.Key(press(key.CodeR, 0))
+
+	assert.Equal(t, Intro, game.state.State())
+	assert.Equal(t, 0, game.level.moves)
+	assert.Equal(t, initial, game.level.blockSignature())
+}
+
+func TestKeyContinue(t *testing.T) {
+	game := newStepGame(ParseLevel(scoreLevel))
+	game.Press(0, true)
+	game.settle()
+	assert.Equal(t, Won, game.state.State())
+	for i := 0; i < wonDelay; i++ {
+		game.frame(nil)
+	}
+
+	game.Key(press(key.CodeReturnEnter, 0))
+
+	assert.Equal(t, Intro, game.state.State())
+	assert.Equal(t, 1, game.currentLevel)
+}
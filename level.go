@@ -2,9 +2,11 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,11 +20,36 @@ type Level struct {
 	blocks       [][]*Block
 	switches     []*Switch
 	winSignature [][]Color
-	// rotated represents the historics of rotations
-	rotated []int
-	// rotating represents a rotate which
+	// goal is the win condition, the win signature
+	// unless the level has a pattern goal.
+	goal Goal
+	// rotated represents the historics of moves
+	rotated []Move
+	// undone holds the moves cancelled by the player,
+	// the last one is the next to redo.
+	undone []Move
+	// jumping is true while the history is replayed
+	// or rewound up to the move jump.
+	jumping bool
+	jump    int
+	// hurry shortens the animations of
+	// the moves queued by the player.
+	hurry bool
+	// dragged is the part of the next move
+	// already turned by the player finger.
+	dragged float32
+	// rotating represents the move which
 	// is currently rotating
-	rotating *Switch
+	rotating Move
+	// undoing is true when the current move
+	// cancels the last one
+	undoing bool
+	// hex is true for the hexagonal boards, where the
+	// odd lines are shifted by half a block to the right.
+	hex bool
+	// wrap is true if the switches can exceed the board
+	// edges and wrap around to the opposite ones.
+	wrap     bool
 	solution string
 	maxMoves int
 	moves    int
@@ -30,6 +57,10 @@ type Level struct {
 
 type Color rune
 
+// Ignored marks the win cells where any block color
+// is accepted, unlike Empty which requires no block.
+const Ignored = '.'
+
 const (
 	Empty       = '-'
 	Red         = '0'
@@ -53,56 +84,340 @@ const (
 type Block struct {
 	Object
 	Color Color
+	// locked blocks never move
+	locked bool
 }
 
+// Locked is the block modifier which pins the previous block
+// in the level files.
+const Locked = '*'
+
 type Switch struct {
 	Object
 	line, col int
-	name      string
+	// lines and cols are the dimensions of the switch
+	// footprint, 2x2 by default.
+	lines, cols int
+	name        string
+	dir         Direction
+	// uses is the number of times the switch can be pressed,
+	// 0 means unlimited.
+	uses, remaining int
+	// links are the switches rotated when this one is pressed
+	links []Link
+	// ghosts are the copies of a switch wrapping around
+	// the board edges, displayed on the opposite edges.
+	ghosts []*Object
+	kind   SwitchKind
+}
+
+// SwitchKind indicates how a switch moves the blocks.
+type SwitchKind int
+
+const (
+	// Rotor rotates the blocks around the switch
+	Rotor SwitchKind = iota
+	// RowShift shifts a whole line of blocks, the switch
+	// is an arrow in the margin.
+	RowShift
+	// ColShift shifts a whole column of blocks
+	ColShift
+	// HexDown rotates the 3 hexagonal blocks around the vertex
+	// below the blocks line,col and line,col+1.
+	HexDown
+	// HexUp rotates the hexagonal block line,col with the
+	// 2 blocks below it.
+	HexUp
+)
+
+const (
+	// Hex is the header line of the hexagonal level files.
+	Hex = "hex"
+	// Wrap is the header line of the level files whose
+	// switches wrap around the board edges.
+	Wrap = "wrap"
+)
+
+// Link represents a switch rotated by another one.
+type Link struct {
+	sw int
+	// same is false if the linked switch rotates
+	// in the opposite direction
+	same bool
+}
+
+// cell represents a block position in the board.
+type cell struct {
+	line, col int
 }
 
-// Blocks returns the block arround the switch in parameter.
+// Direction indicates how a switch can rotate.
+type Direction int
+
+const (
+	Clockwise Direction = iota
+	CounterClockwise
+	// BothWays lets the player choose the direction
+	BothWays
+)
+
+// Rotation represents a turn of a switch.
+type Rotation struct {
+	sw        int
+	clockwise bool
+}
+
+// Move represents the rotations made by a switch press,
+// starting by the pressed switch followed by its links.
+type Move []Rotation
+
+// Blocks returns the block arround the switch in parameter,
+// in the clockwise rotation order. Locked blocks are excluded.
 func (l *Level) Blocks(sw *Switch) []*Block {
-	topLeft := l.blocks[sw.line][sw.col]
-	topRight := l.blocks[sw.line][sw.col+1]
-	bottomLeft := l.blocks[sw.line+1][sw.col]
-	bottomRight := l.blocks[sw.line+1][sw.col+1]
-	return []*Block{topLeft, topRight, bottomLeft, bottomRight}
+	ring := l.ring(sw)
+	blocks := make([]*Block, len(ring))
+	for i, c := range ring {
+		blocks[i] = l.blocks[c.line][c.col]
+	}
+	return blocks
+}
+
+// cells returns the cells of the switch ring. Switches
+// exceeding the board wrap around to the opposite edge.
+func (l *Level) cells(sw *Switch) []cell {
+	ring := sw.ring()
+	lines, cols := len(l.blocks), len(l.blocks[0])
+	for i := range ring {
+		ring[i].line %= lines
+		ring[i].col %= cols
+	}
+	return ring
+}
+
+// wraps returns true if the switch footprint exceeds the
+// bottom and right board edges.
+func (l *Level) wraps(sw *Switch) (bottom, right bool) {
+	if sw.hex() {
+		// Hexagonal boards don't wrap
+		return false, false
+	}
+	return sw.line+sw.lines > len(l.blocks), sw.col+sw.cols > len(l.blocks[0])
+}
+
+// ring returns the cells of the switch ring which can move.
+func (l *Level) ring(sw *Switch) []cell {
+	var ring []cell
+	for _, c := range l.cells(sw) {
+		if !l.blocks[c.line][c.col].locked {
+			ring = append(ring, c)
+		}
+	}
+	return ring
+}
+
+// enabled returns false if the switch has not enough
+// unlocked blocks to rotate, or if it has no more uses.
+func (l *Level) enabled(sw *Switch) bool {
+	return len(l.ring(sw)) > 1 && !sw.exhausted()
+}
+
+// shift returns true if the switch is a row or column shift.
+func (s *Switch) shift() bool {
+	return s.kind == RowShift || s.kind == ColShift
+}
+
+// hex returns true if the switch rotates hexagonal blocks.
+func (s *Switch) hex() bool {
+	return s.kind == HexDown || s.kind == HexUp
+}
+
+// exhausted returns true if the switch can't be pressed anymore.
+func (s *Switch) exhausted() bool {
+	return s.uses > 0 && s.remaining == 0
+}
+
+// ring returns the cells on the border of the switch footprint,
+// clockwise from the top left one. A clockwise rotation moves
+// each block to the next cell of the ring.
+func (s *Switch) ring() []cell {
+	var ring []cell
+	// The lower line of a hex switch is shifted to
+	// the right when the upper one is even.
+	odd := s.line % 2
+	switch s.kind {
+	case HexDown:
+		return []cell{{s.line, s.col}, {s.line, s.col + 1}, {s.line + 1, s.col + odd}}
+	case HexUp:
+		return []cell{{s.line, s.col}, {s.line + 1, s.col + odd}, {s.line + 1, s.col + odd - 1}}
+	}
+	bottom, right := s.line+s.lines-1, s.col+s.cols-1
+	if s.lines == 1 || s.cols == 1 {
+		// Shifts move the blocks toward the bottom right
+		for li := s.line; li <= bottom; li++ {
+			for co := s.col; co <= right; co++ {
+				ring = append(ring, cell{li, co})
+			}
+		}
+		return ring
+	}
+	for co := s.col; co < right; co++ {
+		ring = append(ring, cell{s.line, co})
+	}
+	for li := s.line; li < bottom; li++ {
+		ring = append(ring, cell{li, right})
+	}
+	for co := right; co > s.col; co-- {
+		ring = append(ring, cell{bottom, co})
+	}
+	for li := bottom; li > s.line; li-- {
+		ring = append(ring, cell{li, s.col})
+	}
+	return ring
 }
 
 func (s *Switch) String() string {
 	return fmt.Sprintf("sw{line:%d, col:%d}", s.line, s.col)
 }
 
-func (l *Level) Copy() Level {
+// format returns the switch as written in the level files.
+func (s *Switch) format() string {
+	var str string
+	switch s.kind {
+	case RowShift:
+		str = fmt.Sprintf("row,%d", s.line)
+	case ColShift:
+		str = fmt.Sprintf("col,%d", s.col)
+	default:
+		str = fmt.Sprintf("%d,%d", s.line, s.col)
+	}
+	if s.kind == Rotor && (s.lines != 2 || s.cols != 2) {
+		str += fmt.Sprintf(",%dx%d", s.lines, s.cols)
+	}
+	if s.kind == HexUp {
+		str += ",up"
+	}
+	switch s.dir {
+	case CounterClockwise:
+		str += ",ccw"
+	case BothWays:
+		str += ",both"
+	}
+	if s.uses > 0 {
+		str += fmt.Sprintf(",%d", s.uses)
+	}
+	for _, link := range s.links {
+		if link.same {
+			str += fmt.Sprintf(",+%d", link.sw)
+		} else {
+			str += fmt.Sprintf(",-%d", link.sw)
+		}
+	}
+	return str
+}
+
+// setOption reads a switch option from the level file.
+func (s *Switch) setOption(opt string) {
+	switch opt {
+	case "cw":
+		s.dir = Clockwise
+	case "ccw":
+		s.dir = CounterClockwise
+	case "both":
+		s.dir = BothWays
+	case "up":
+		if !s.hex() {
+			panic(fmt.Sprintf("only hex switches can have the option %q", opt))
+		}
+		s.kind = HexUp
+	case "3x3", "2x3", "3x2":
+		if s.kind != Rotor {
+			panic(fmt.Sprintf("only rotors can have the size option %q", opt))
+		}
+		s.lines, s.cols = int(opt[0]-'0'), int(opt[2]-'0')
+	case "+", "-":
+		panic(fmt.Sprintf("missing linked switch in option %q", opt))
+	default:
+		if opt[0] == '+' || opt[0] == '-' {
+			// Link to the switch at this index, rotating
+			// in the same or the opposite direction.
+			s.links = append(s.links, Link{sw: atoi(opt[1:]), same: opt[0] == '+'})
+			return
+		}
+		// A number limits the switch uses
+		uses, err := strconv.Atoi(opt)
+		if err != nil || uses <= 0 {
+			panic(fmt.Sprintf("unknown switch option %q", opt))
+		}
+		s.uses, s.remaining = uses, uses
+	}
+}
+
+// directions returns the rotations allowed by the switch,
+// true for clockwise.
+func (s *Switch) directions() []bool {
+	switch s.dir {
+	case CounterClockwise:
+		return []bool{false}
+	case BothWays:
+		return []bool{true, false}
+	}
+	return []bool{true}
+}
+
+// clockwise returns the direction of the rotation when the
+// switch is pressed at x,y. Switches rotating both ways turn
+// clockwise when pressed on their right half.
+func (s *Switch) clockwise(x, y float32) bool {
+	switch s.dir {
+	case CounterClockwise:
+		return false
+	case BothWays:
+		o := s.hit(x, y)
+		return o == nil || x >= o.X+switchSize/2
+	}
+	return true
+}
+
+// hit returns the switch object or the switch ghost
+// at the coordinates.
+func (s *Switch) hit(x, y float32) *Object {
+	if touched(&s.Object, x, y) {
+		return &s.Object
+	}
+	for _, o := range s.ghosts {
+		if touched(o, x, y) {
+			return o
+		}
+	}
+	return nil
+}
+
+func (l *Level) Copy() *Level {
 	lcp := new(Level)
 	lcp.blocks = make([][]*Block, len(l.blocks))
 	for i := range l.blocks {
 		lcp.blocks[i] = make([]*Block, len(l.blocks[i]))
 		for j := range l.blocks[i] {
-			lcp.blocks[i][j] = &Block{Color: l.blocks[i][j].Color}
+			lcp.blocks[i][j] = &Block{Color: l.blocks[i][j].Color, locked: l.blocks[i][j].locked}
 		}
 	}
 
 	lcp.switches = make([]*Switch, len(l.switches))
 	for i := range l.switches {
 		sw := l.switches[i]
-		lcp.switches[i] = &Switch{col: sw.col, line: sw.line, name: sw.name}
+		lcp.switches[i] = &Switch{col: sw.col, line: sw.line, lines: sw.lines, cols: sw.cols, name: sw.name, dir: sw.dir, kind: sw.kind,
+			uses: sw.uses, remaining: sw.remaining, links: sw.links}
 	}
 	lcp.winSignature = l.winSignature
-	return *lcp
+	lcp.hex = l.hex
+	lcp.wrap = l.wrap
+	lcp.goal = l.goal
+	return lcp
 }
 
 // Win returns true if player has win.
 func (l *Level) Win() bool {
-	for i := range l.winSignature {
-		for j := range l.winSignature[i] {
-			if l.winSignature[i][j] != l.blocks[i][j].Color {
-				return false
-			}
-		}
-	}
-	return true
+	return l.goal.Reached(l.colors())
 }
 
 // Loose returns true if player has loose.
@@ -119,29 +434,111 @@ func (l *Level) UndoLastMove() {
 	if l.rotating != nil {
 		return
 	}
-	sw := l.PopLastRotated()
-	if sw != nil {
-		g.level.rotating = sw
-		blocks := l.Blocks(sw)
-		for i := range blocks {
-			b := blocks[i]
-			v := switchSize / 2
-			b.Time = 0
-			b.Rx, b.Ry = sw.X+v, sw.Y+v
-			b.Sx, b.Sy = b.Rx, b.Ry
-			b.Action = ActionFunc(blockRotateInverse)
+	l.undo(l.duration(undoDuration))
+}
+
+// undo rewinds the last move of the history.
+func (l *Level) undo(duration clock.Time) {
+	m := l.PopLastRotated()
+	if m != nil {
+		l.rotating = m
+		l.undoing = true
+		l.undone = append(l.undone, m)
+		// Turn in the opposite direction of the move
+		for _, r := range m {
+			l.turnBlocks(l.switches[r.sw], !r.clockwise, duration)
 		}
 	}
 }
 
-func (l *Level) PopLastRotated() *Switch {
+// turnBlocks starts the animation of the blocks of the switch.
+// Each block turns around its own pivot, so it lands on the
+// next cell of the ring whatever the switch footprint.
+func (l *Level) turnBlocks(sw *Switch, clockwise bool, duration clock.Time) {
+	blocks := l.Blocks(sw)
+	if sw.shift() {
+		// Slide the blocks to their next cell
+		dx, dy := blockSize+blockPadding*2, float32(0)
+		if sw.kind == ColShift {
+			dx, dy = dy, dx
+		}
+		if !clockwise {
+			dx, dy = -dx, -dy
+		}
+		// The last block in the slide direction
+		// wraps around to the first cell.
+		last := blocks[0]
+		for _, b := range blocks {
+			if b.X*dx+b.Y*dy > last.X*dx+last.Y*dy {
+				last = b
+			}
+		}
+		n := float32(len(blocks))
+		for _, b := range blocks {
+			b.Reset()
+			s := slide{dx: dx, dy: dy, duration: duration}
+			if b == last {
+				s.wx, s.wy = -dx*n, -dy*n
+			}
+			b.Action = s
+		}
+		return
+	}
+	if bottom, right := l.wraps(sw); bottom || right {
+		// The blocks can't turn across the board,
+		// flip them instead to the color of the
+		// previous block of the ring.
+		n := len(blocks)
+		for i, b := range blocks {
+			prev := blocks[(i+n-1)%n]
+			if !clockwise {
+				prev = blocks[(i+1)%n]
+			}
+			b.Reset()
+			b.Action = flip{next: prev.Color, duration: duration}
+		}
+		return
+	}
+	angle := pivotBlocks(blocks, clockwise)
+	for _, b := range blocks {
+		b.Time = 0
+		b.Action = turn{angle: angle, duration: duration, from: l.dragged}
+	}
+}
+
+// pivotBlocks places the pivot of each block so it turns to
+// the next cell of the ring, and returns the rotation angle.
+func pivotBlocks(blocks []*Block, clockwise bool) float32 {
+	angle := TwoPi / float32(len(blocks))
+	if !clockwise {
+		angle = -angle
+	}
+	for i := range blocks {
+		b := blocks[i]
+		var next *Block
+		if clockwise {
+			next = blocks[(i+1)%len(blocks)]
+		} else {
+			next = blocks[(i+len(blocks)-1)%len(blocks)]
+		}
+		b.Rx, b.Ry = pivot(b.X+b.Width/2, b.Y+b.Height/2, next.X+next.Width/2, next.Y+next.Height/2, angle)
+		b.Sx, b.Sy = b.Rx, b.Ry
+	}
+	return angle
+}
+
+func (l *Level) PopLastRotated() Move {
 	if len(l.rotated) == 0 {
 		return nil
 	}
 	i := len(l.rotated) - 1
 	res := l.rotated[i]
 	l.rotated = l.rotated[:i]
-	return l.switches[res]
+	if sw := l.switches[res[0].sw]; sw.uses > 0 {
+		// Give back the use
+		sw.remaining++
+	}
+	return res
 }
 
 func (b *Block) Layout(line, col int, size, padding float32, dx, dy float32) {
@@ -153,6 +550,30 @@ func (b *Block) Layout(line, col int, size, padding float32, dx, dy float32) {
 	b.Data = b
 }
 
+// LayoutHex is the Layout of the hexagonal boards. The block
+// object remains a square, the hexagon fits inside.
+func (b *Block) LayoutHex(line, col int, size, padding float32, dx, dy float32) {
+	cx, cy := hexCenter(line, col, size, padding, dx, dy)
+	b.X = cx - size/2
+	b.Y = cy - size/2
+	b.Width = size
+	b.Height = size
+	b.Data = b
+}
+
+// hexCenter returns the center of the hexagonal block. The
+// hexagons have a pointy top, so the lines overlap by a quarter
+// of the block size.
+func hexCenter(line, col int, size, padding float32, dx, dy float32) (float32, float32) {
+	w := size*Sqrt3/2 + padding
+	x := dx + float32(col)*w + w/2
+	if line%2 == 1 {
+		x += w / 2
+	}
+	y := dy + float32(line)*(size*3/4+padding) + size/2
+	return x, y
+}
+
 func (l *Level) addBlock(color Color, line, col int) {
 	b := &Block{Color: color}
 	b.Action = wait{until: clock.Time(line*10 + col*5), next: ActionFunc(blockPopIn)}
@@ -161,9 +582,10 @@ func (l *Level) addBlock(color Color, line, col int) {
 
 // addSwitch appends a new switch at the bottom right
 // of the coordinates in parameters.
-func (l *Level) addSwitch(line, col int) {
+func (l *Level) addSwitch(line, col int) *Switch {
 	s := &Switch{
 		line: line, col: col,
+		lines: 2, cols: 2,
 		name: determineName(line, col),
 	}
 	s.Object = Object{
@@ -172,13 +594,93 @@ func (l *Level) addSwitch(line, col int) {
 	}
 	l.switches = append(l.switches, s)
 	log.Println("Switch added", s.X, s.Y)
+	return s
+}
+
+// addHexSwitch appends a new switch at the vertex
+// below the blocks line,col and line,col+1.
+func (l *Level) addHexSwitch(line, col int) *Switch {
+	s := l.addSwitch(line, col)
+	s.kind = HexDown
+	return s
+}
+
+// addShift appends a new switch shifting the line
+// or the column at index.
+func (l *Level) addShift(kind SwitchKind, index int) *Switch {
+	if len(l.blocks) == 0 {
+		panic("shift declared before the blocks")
+	}
+	var s *Switch
+	if kind == RowShift {
+		s = l.addSwitch(index, 0)
+		s.lines, s.cols = 1, len(l.blocks[0])
+	} else {
+		s = l.addSwitch(0, index)
+		s.lines, s.cols = len(l.blocks), 1
+	}
+	s.kind = kind
+	s.name = "x"
+	return s
 }
 
 func (s *Switch) Layout(size float32) {
 	v := switchSize / 2
 	linef, colf := float32(s.line), float32(s.col)
-	s.X = xMin + (colf+1)*blockSize + colf*blockPadding*2 - v
-	s.Y = yMin + (linef+1)*blockSize + linef*blockPadding*2 - v
+	if s.shift() {
+		s.layoutArrow(linef, colf)
+		return
+	}
+	if s.hex() {
+		s.layoutHex()
+		return
+	}
+	// The switch is at the center of its footprint
+	halfLines, halfCols := float32(s.lines)/2, float32(s.cols)/2
+	s.X = xMin + (colf+halfCols)*blockSize + colf*blockPadding*2 - v
+	s.Y = yMin + (linef+halfLines)*blockSize + linef*blockPadding*2 - v
+	s.Width = switchSize
+	s.Height = switchSize
+}
+
+// layoutArrow places the shift arrows in the margin,
+// before the line or column for the shifts to the bottom
+// right, after for the others.
+func (s *Switch) layoutArrow(linef, colf float32) {
+	s.Width = padding
+	s.Height = padding
+	boardWidth := float32(s.cols) * (blockSize + blockPadding*2)
+	boardHeight := float32(s.lines) * (blockSize + blockPadding*2)
+	switch s.kind {
+	case RowShift:
+		s.Y = yMin + (linef+.5)*blockSize + linef*blockPadding*2 - padding/2
+		if s.dir == CounterClockwise {
+			s.X = xMin + boardWidth
+		} else {
+			s.X = xMin - padding
+		}
+	case ColShift:
+		s.X = xMin + (colf+.5)*blockSize + colf*blockPadding*2 - padding/2
+		if s.dir == CounterClockwise {
+			s.Y = yMin + boardHeight
+		} else {
+			s.Y = yMin - padding
+		}
+	}
+}
+
+// layoutHex places the switch at the center of its blocks.
+func (s *Switch) layoutHex() {
+	var x, y float32
+	ring := s.ring()
+	for _, c := range ring {
+		cx, cy := hexCenter(c.line, c.col, blockSize, blockPadding, xMin, yMin)
+		x += cx
+		y += cy
+	}
+	n := float32(len(ring))
+	s.X = x/n - switchSize/2
+	s.Y = y/n - switchSize/2
 	s.Width = switchSize
 	s.Height = switchSize
 }
@@ -220,53 +722,127 @@ func determineName(line, col int) string {
 // and activate it.
 func (l *Level) PressSwitch(x, y float32) {
 	// Handle click only when no switch are rotating
-	if l.rotating == nil {
-		if i, s := l.findSwitch(x, y); s != nil {
-			l.rotating = s
-			l.triggerSwitch(i)
-		}
+	if l.rotating != nil {
+		return
+	}
+	if i, clockwise, ok := l.pressed(x, y); ok {
+		l.triggerSwitch(i, clockwise)
 	}
 }
 
-func (l *Level) triggerSwitchName(name string) {
+// pressed returns the switch which can be pressed at the
+// coordinates, and its rotation direction.
+func (l *Level) pressed(x, y float32) (int, bool, bool) {
+	if i, s := l.findSwitch(x, y); s != nil && l.enabled(s) {
+		return i, s.clockwise(x, y), true
+	}
+	return -1, false, false
+}
+
+// switchNamed returns the index of the switch bound to
+// the numpad key name, or -1 if there's none.
+func (l *Level) switchNamed(name string) int {
 	for i := 0; i < len(l.switches); i++ {
 		if l.switches[i].name == name {
-			l.triggerSwitch(i)
-			return
+			return i
 		}
 	}
+	return -1
 }
 
-func (l *Level) triggerSwitch(i int) {
-	sw := l.switches[i]
-	blocks := l.Blocks(sw)
-	for i := range blocks {
-		b := blocks[i]
-		v := switchSize / 2
-		// Prepare a rotation around the center of the switch
-		b.Rx, b.Ry = sw.X+v, sw.Y+v
-		b.Sx, b.Sy = b.Rx, b.Ry
-		b.Time = 0
-		b.Action = ActionFunc(blockRotate)
+// move returns the rotations made by pressing the switch.
+func (l *Level) move(i int, clockwise bool) Move {
+	m := Move{{sw: i, clockwise: clockwise}}
+	for _, link := range l.switches[i].links {
+		if len(l.ring(l.switches[link.sw])) < 2 {
+			// Linked switch disabled by the locked blocks
+			continue
+		}
+		m = append(m, Rotation{sw: link.sw, clockwise: clockwise == link.same})
+	}
+	return m
+}
+
+func (l *Level) triggerSwitch(i int, clockwise bool) {
+	// A new move can't be followed by the undone ones
+	l.undone = nil
+	l.play(l.move(i, clockwise), l.duration(rotateDuration))
+}
+
+// duration returns the animation duration of
+// the moves, shortened when the level hurries.
+func (l *Level) duration(d clock.Time) clock.Time {
+	if l.hurry {
+		return queuedDuration
+	}
+	return d
+}
+
+// play starts the rotations of the move and
+// appends it to the history.
+func (l *Level) play(m Move, duration clock.Time) {
+	sw := l.switches[m[0].sw]
+	l.rotating = m
+	if sw.uses > 0 {
+		sw.remaining--
+	}
+	for _, r := range l.rotating {
+		s := l.switches[r.sw]
+		l.turnBlocks(s, r.clockwise, duration)
+		// Only the switch dragged by the player is partly turned
+		l.dragged = 0
+		if s.shift() || l.jumping {
+			// Arrows don't spin, neither the switches
+			// during a jump.
+			continue
+		}
+		if r.clockwise {
+			s.Action = ActionFunc(switchRotate)
+		} else {
+			s.Action = ActionFunc(switchRotateInverse)
+		}
 	}
-	sw.Action = ActionFunc(switchRotate)
-	l.rotated = append(l.rotated, i)
+	l.rotated = append(l.rotated, l.rotating)
 }
 
 const touchDelta = 8
 
 func (l *Level) findSwitch(x, y float32) (int, *Switch) {
+	if l.hex {
+		return l.findHexSwitch(x, y)
+	}
 	for i, s := range l.switches {
-		if x >= s.X-touchDelta &&
-			x <= s.X+switchSize+touchDelta &&
-			y >= s.Y-touchDelta &&
-			y <= s.Y+switchSize+touchDelta {
+		if s.hit(x, y) != nil {
 			return i, s
 		}
 	}
 	return -1, nil
 }
 
+// findHexSwitch returns the switch closest to the coordinates.
+// The vertices of the hexagonal boards are too close to rely on
+// the switch bounds only.
+func (l *Level) findHexSwitch(x, y float32) (int, *Switch) {
+	found, min := -1, switchSize/2+touchDelta
+	for i, s := range l.switches {
+		dx, dy := x-(s.X+s.Width/2), y-(s.Y+s.Height/2)
+		if d := float32(math.Sqrt(float64(dx*dx + dy*dy))); d <= min {
+			found, min = i, d
+		}
+	}
+	if found < 0 {
+		return -1, nil
+	}
+	return found, l.switches[found]
+}
+
+func touched(o *Object, x, y float32) bool {
+	return x >= o.X-touchDelta &&
+		x <= o.X+o.Width+touchDelta &&
+		y >= o.Y-touchDelta &&
+		y <= o.Y+o.Height+touchDelta
+}
+
 func (l *Level) blockSignature() string {
 	var signature bytes.Buffer
 	for i := 0; i < len(l.blocks); i++ {
@@ -290,9 +866,18 @@ func ctoa(c Color) string {
 	return fmt.Sprintf("%d", c)
 }
 
+// hasLevel returns true if the level number exists.
+func hasLevel(level int) bool {
+	f, err := asset.Open(fmt.Sprintf("levels/%d", level))
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
 // LoadLevel loads the level number in parameter
-func LoadLevel(level int) Level {
-	g.listen = false
+func LoadLevel(level int) *Level {
 	f, err := asset.Open(fmt.Sprintf("levels/%d", level))
 	if err != nil {
 		panic(err)
@@ -302,15 +887,18 @@ func LoadLevel(level int) Level {
 		panic(err)
 	}
 	l := ParseLevel(string(b))
+	if err := l.Validate(); err != nil {
+		panic(fmt.Sprintf("level %d: %v", level, err))
+	}
 	log.Printf("Level loaded %d\n", level)
 	return l
 }
 
 // ParseLevel reads level information
-func ParseLevel(str string) Level {
+func ParseLevel(str string) *Level {
 	lines := strings.Split(str, "\n")
 	step := 0
-	l := Level{}
+	l := &Level{}
 
 	for i := 0; i < len(lines); i++ {
 		if len(lines[i]) == 0 {
@@ -319,17 +907,58 @@ func ParseLevel(str string) Level {
 		}
 		switch step {
 		case 0:
-			// read block colors
-			bline := make([]*Block, len(lines[i]))
+			if len(l.blocks) == 0 && lines[i] == Hex {
+				// The blocks are hexagons
+				l.hex = true
+				continue
+			}
+			if len(l.blocks) == 0 && lines[i] == Wrap {
+				// The switches can wrap around the edges
+				l.wrap = true
+				continue
+			}
+			// read block colors, a block followed by
+			// the Locked modifier is pinned.
+			bline := make([]*Block, len(lines[i])-strings.Count(lines[i], string(Locked)))
 			l.blocks = append(l.blocks, bline)
-			for j, c := range lines[i] {
-				l.addBlock(Color(c), i, j)
+			line := len(l.blocks) - 1
+			j := 0
+			for _, c := range lines[i] {
+				if c == Locked {
+					if j == 0 {
+						panic(fmt.Sprintf("lock modifier without block at line %d", i))
+					}
+					l.blocks[line][j-1].locked = true
+					continue
+				}
+				l.addBlock(Color(c), line, j)
+				j++
 			}
 		case 1:
 			// read switch locations
 			tokens := strings.Split(lines[i], ",")
-			l.addSwitch(atoi(tokens[0]), atoi(tokens[1]))
+			var sw *Switch
+			switch tokens[0] {
+			case "row":
+				sw = l.addShift(RowShift, atoi(tokens[1]))
+			case "col":
+				sw = l.addShift(ColShift, atoi(tokens[1]))
+			default:
+				if l.hex {
+					sw = l.addHexSwitch(atoi(tokens[0]), atoi(tokens[1]))
+				} else {
+					sw = l.addSwitch(atoi(tokens[0]), atoi(tokens[1]))
+				}
+			}
+			for _, opt := range tokens[2:] {
+				sw.setOption(opt)
+			}
 		case 2:
+			if goal := parseGoal(lines[i], l.hex); goal != nil {
+				// The win is a pattern
+				l.goal = goal
+				continue
+			}
 			//read win
 			wline := make([]Color, len(lines[i]))
 			for j, c := range lines[i] {
@@ -344,28 +973,181 @@ func ParseLevel(str string) Level {
 			l.solution = lines[i]
 		}
 	}
+	if l.goal == nil {
+		l.goal = signatureGoal{signature: l.winSignature, hex: l.hex}
+	}
 	return l
 }
 
+// Validate checks the level read by ParseLevel is playable.
+func (l *Level) Validate() error {
+	if len(l.blocks) == 0 {
+		return errors.New("no blocks")
+	}
+	cols := len(l.blocks[0])
+	counts := make(map[Color]int)
+	for i := range l.blocks {
+		if len(l.blocks[i]) != cols {
+			return fmt.Errorf("line %d has %d blocks, expected %d", i, len(l.blocks[i]), cols)
+		}
+		for j := range l.blocks[i] {
+			c := l.blocks[i][j].Color
+			if _, ok := colorTexMap[c]; !ok {
+				return fmt.Errorf("unknown color %q at %d,%d", c, i, j)
+			}
+			counts[c]++
+		}
+	}
+	if len(l.switches) == 0 {
+		return errors.New("no switches")
+	}
+	for _, sw := range l.switches {
+		if l.hex != sw.hex() {
+			return fmt.Errorf("switch %s doesn't fit the board kind", sw.format())
+		}
+		if sw.hex() {
+			for _, c := range sw.ring() {
+				if c.line < 0 || c.col < 0 || c.line >= len(l.blocks) || c.col >= cols {
+					return fmt.Errorf("switch %s out of the board", sw.format())
+				}
+			}
+		}
+		if sw.line < 0 || sw.col < 0 || sw.line >= len(l.blocks) || sw.col >= cols ||
+			sw.lines > len(l.blocks) || sw.cols > cols {
+			return fmt.Errorf("switch %s out of the board", sw.format())
+		}
+		if bottom, right := l.wraps(sw); (bottom || right) && !l.wrap {
+			return fmt.Errorf("switch %s exceeds the board, the level doesn't wrap", sw.format())
+		}
+		if sw.shift() && sw.dir == BothWays {
+			return fmt.Errorf("shift %s can't move both ways", sw.format())
+		}
+		if sw.uses > 9 {
+			// Remaining uses are displayed with one digit
+			return fmt.Errorf("switch %s has more than 9 uses", sw.format())
+		}
+		// The switches of a move must not share blocks,
+		// because they rotate at the same time.
+		used := make(map[cell]bool)
+		for _, c := range l.cells(sw) {
+			used[c] = true
+		}
+		for _, link := range sw.links {
+			if link.sw < 0 || link.sw >= len(l.switches) {
+				return fmt.Errorf("switch %s is linked to an unknown switch", sw.format())
+			}
+			for _, c := range l.cells(l.switches[link.sw]) {
+				if used[c] {
+					return fmt.Errorf("switch %s is linked to an overlapping switch", sw.format())
+				}
+				used[c] = true
+			}
+		}
+	}
+	if l.maxMoves <= 0 {
+		return errors.New("max moves must be positive")
+	}
+	if _, ok := l.goal.(distinctRowsGoal); ok {
+		// Each row must be able to hold one block of each color
+		if len(counts) != cols {
+			return fmt.Errorf("%d colors can't fill rows of %d blocks", len(counts), cols)
+		}
+		for c, n := range counts {
+			if n != len(l.blocks) {
+				return fmt.Errorf("color %q must have one block per row", c)
+			}
+		}
+	}
+	if _, ok := l.goal.(signatureGoal); !ok {
+		// No win signature for the pattern goals
+		return nil
+	}
+	if len(l.winSignature) != len(l.blocks) {
+		return fmt.Errorf("win has %d lines, expected %d", len(l.winSignature), len(l.blocks))
+	}
+	partial := false
+	for i := range l.winSignature {
+		if len(l.winSignature[i]) != cols {
+			return fmt.Errorf("win line %d has %d blocks, expected %d", i, len(l.winSignature[i]), cols)
+		}
+		for j, c := range l.winSignature[i] {
+			if c == Ignored {
+				partial = true
+				continue
+			}
+			if _, ok := colorTexMap[c]; !ok {
+				return fmt.Errorf("unknown win color %q at %d,%d", c, i, j)
+			}
+			if b := l.blocks[i][j]; b.locked && b.Color != c {
+				return fmt.Errorf("locked block at %d,%d can't reach the win color %q", i, j, c)
+			}
+			counts[c]--
+		}
+	}
+	for c, n := range counts {
+		if n < 0 || n > 0 && !partial {
+			// The ignored cells can hold the extra blocks
+			return fmt.Errorf("color %q count differs between blocks and win", c)
+		}
+	}
+	return nil
+}
+
+// applyRotating applies the current move to the level blocks.
+// Use a mutex because this must be done only one time.
+func (l *Level) applyRotating() {
+	l.Lock()
+	defer l.Unlock()
+	if l.rotating == nil {
+		return
+	}
+	if l.undoing {
+		for i := len(l.rotating) - 1; i >= 0; i-- {
+			r := l.rotating[i]
+			l.rotateBlocks(l.switches[r.sw], !r.clockwise)
+		}
+		l.moves--
+	} else {
+		for _, r := range l.rotating {
+			l.rotateBlocks(l.switches[r.sw], r.clockwise)
+		}
+		l.moves++
+	}
+	log.Println("Move applied", l.rotating, "undo", l.undoing)
+	l.rotating = nil
+	l.undoing = false
+}
+
 // RotateSwitch swaps bocks according to the 90d rotation
-func (l *Level) RotateSwitch(s *Switch) {
-	li, co := s.line, s.col
-	log.Println("Swap from", s.name, li, co)
-	color := l.blocks[li][co].Color
-	l.blocks[li][co].Color = l.blocks[li+1][co].Color
-	l.blocks[li+1][co].Color = l.blocks[li+1][co+1].Color
-	l.blocks[li+1][co+1].Color = l.blocks[li][co+1].Color
-	l.blocks[li][co+1].Color = color
+// in the direction in parameter.
+func (l *Level) RotateSwitch(s *Switch, clockwise bool) {
+	log.Println("Swap from", s.name, s.line, s.col, clockwise)
+	l.rotateBlocks(s, clockwise)
 	l.moves++
 }
 
-// RotateSwitchInverse swaps bocks according to the -90d rotation
-func (l *Level) RotateSwitchInverse(s *Switch) {
-	li, co := s.line, s.col
-	color := l.blocks[li][co].Color
-	l.blocks[li][co].Color = l.blocks[li][co+1].Color
-	l.blocks[li][co+1].Color = l.blocks[li+1][co+1].Color
-	l.blocks[li+1][co+1].Color = l.blocks[li+1][co].Color
-	l.blocks[li+1][co].Color = color
+// RotateSwitchInverse cancels a rotation made by RotateSwitch.
+func (l *Level) RotateSwitchInverse(s *Switch, clockwise bool) {
+	l.rotateBlocks(s, !clockwise)
 	l.moves--
 }
+
+// rotateBlocks moves the colors of the switch blocks
+// to the next cell of its ring.
+func (l *Level) rotateBlocks(s *Switch, clockwise bool) {
+	blocks := l.Blocks(s)
+	n := len(blocks)
+	if clockwise {
+		color := blocks[n-1].Color
+		for i := n - 1; i > 0; i-- {
+			blocks[i].Color = blocks[i-1].Color
+		}
+		blocks[0].Color = color
+	} else {
+		color := blocks[0].Color
+		for i := 0; i < n-1; i++ {
+			blocks[i].Color = blocks[i+1].Color
+		}
+		blocks[n-1].Color = color
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+const (
+	// MaxLives is the number of lives of the LoseLife policy
+	MaxLives = 3
+	// PackSize is the number of levels of a pack
+	PackSize = 10
+)
+
+// LosePolicy decides where the player goes on
+// once a level is lost.
+type LosePolicy string
+
+const (
+	// LoseRestart plays the level again
+	LoseRestart LosePolicy = "restart"
+	// LoseLife plays the level again while the player has lives,
+	// then goes back to the start of the pack with all the lives.
+	LoseLife LosePolicy = "life"
+	// LosePack goes back to the start of the pack
+	LosePack LosePolicy = "pack"
+)
+
+// ParseLosePolicy returns the policy named s.
+func ParseLosePolicy(s string) (LosePolicy, error) {
+	switch p := LosePolicy(s); p {
+	case LoseRestart, LoseLife, LosePack:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown lose policy %q", s)
+}
+
+// losePolicy returns the policy of the settings, LoseLife
+// if it's unset or unknown.
+func (s Settings) losePolicy() LosePolicy {
+	if s.LosePolicy == "" {
+		return LoseLife
+	}
+	p, err := ParseLosePolicy(string(s.LosePolicy))
+	if err != nil {
+		log.Println(err)
+		return LoseLife
+	}
+	return p
+}
+
+// packStart returns the first level of the pack of the level.
+func packStart(level int) int {
+	return (level-1)/PackSize*PackSize + 1
+}
+
+// Lives returns the remaining lives.
+func (g *Game) Lives() int {
+	return g.store.Progress().Lives
+}
+
+// StartOver applies the lose policy, once the player lost.
+// Custom levels are restarted, they cost no life.
+func (g *Game) StartOver() {
+	level := g.currentLevel
+	switch {
+	case level == 0:
+		// Custom levels have no pack
+	case g.losePolicy == LoseLife:
+		p := g.store.Progress()
+		p.Lives--
+		if p.Lives == 0 {
+			log.Println("No more lives")
+			p.Lives = MaxLives
+			level = packStart(level)
+		}
+		if err := g.store.Save(); err != nil {
+			log.Println("Can't save the lives", err)
+		}
+	case g.losePolicy == LosePack:
+		level = packStart(level)
+	}
+	if g.timer != nil {
+		g.timer = NewTimer(TimeAttackSeconds)
+	}
+	if level == g.currentLevel {
+		g.Restart()
+		return
+	}
+	g.currentLevel = level
+	g.level = LoadLevel(level)
+	g.levelLoaded()
+	g.world.LoadScene()
+}
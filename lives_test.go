@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newLostGame returns a game on the level, with a store.
+func newLostGame(level int, policy LosePolicy) *stepGame {
+	game := newStepGame(LoadLevel(level))
+	game.currentLevel = level
+	game.store, _ = NewStore(&memStorage{})
+	game.losePolicy = policy
+	return game
+}
+
+func TestSettingsLosePolicy(t *testing.T) {
+	assert.Equal(t, LoseLife, Settings{}.losePolicy())
+	assert.Equal(t, LosePack, Settings{LosePolicy: "pack"}.losePolicy())
+	assert.Equal(t, LoseLife, Settings{LosePolicy: "never"}.losePolicy())
+}
+
+func TestParseLosePolicy(t *testing.T) {
+	p, err := ParseLosePolicy("pack")
+
+	assert.Nil(t, err)
+	assert.Equal(t, LosePack, p)
+	_, err = ParseLosePolicy("never")
+	assert.NotNil(t, err)
+}
+
+func TestPackStart(t *testing.T) {
+	assert.Equal(t, 1, packStart(1))
+	assert.Equal(t, 1, packStart(PackSize))
+	assert.Equal(t, PackSize+1, packStart(PackSize+1))
+	assert.Equal(t, PackSize+1, packStart(PackSize+2))
+}
+
+func TestStartOverRestart(t *testing.T) {
+	game := newLostGame(12, LoseRestart)
+	game.level.triggerSwitch(0, true)
+	game.level.applyRotating()
+
+	game.StartOver()
+
+	assert.Equal(t, 12, game.currentLevel)
+	assert.Equal(t, 0, game.level.moves)
+	assert.Equal(t, Intro, game.state.State())
+}
+
+func TestStartOverPack(t *testing.T) {
+	game := newLostGame(12, LosePack)
+
+	game.StartOver()
+
+	assert.Equal(t, 11, game.currentLevel)
+}
+
+func TestStartOverLives(t *testing.T) {
+	game := newLostGame(12, LoseLife)
+	assert.Equal(t, MaxLives, game.Lives())
+
+	for i := 1; i < MaxLives; i++ {
+		game.StartOver()
+		assert.Equal(t, 12, game.currentLevel)
+		assert.Equal(t, MaxLives-i, game.Lives())
+	}
+	game.StartOver()
+
+	assert.Equal(t, 11, game.currentLevel)
+	assert.Equal(t, MaxLives, game.Lives())
+}
+
+func TestStartOverCustomLevel(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	code, _ := EncodeLevel(l)
+	for _, policy := range []LosePolicy{LoseLife, LosePack} {
+		game := newLostGame(12, policy)
+		game.store.Progress().Lives = 1
+		assert.NoError(t, game.EnterCode(code))
+
+		game.StartOver()
+
+		assert.Equal(t, 0, game.currentLevel)
+		assert.Equal(t, 1, game.Lives())
+		assert.Equal(t, l.blockSignature(), game.level.blockSignature())
+	}
+}
+
+func TestStartOverTimer(t *testing.T) {
+	game := newLostGame(12, LoseRestart)
+	game.StartTimeAttack()
+	game.timer.Add(-TimeAttackSeconds)
+
+	game.StartOver()
+
+	assert.Equal(t, TimeAttackSeconds, game.timer.Seconds())
+}
+
+func TestRestartButton(t *testing.T) {
+	game := newLostGame(12, LoseLife)
+	assert.False(t, game.world.restartButton.enabled())
+	game.level.triggerSwitch(0, true)
+	game.level.applyRotating()
+
+	assert.True(t, game.world.restartButton.enabled())
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"flag"
 	"log"
-	"time"
+	"os"
 
 	"golang.org/x/mobile/app"
+	"golang.org/x/mobile/event/key"
 	"golang.org/x/mobile/event/lifecycle"
 	"golang.org/x/mobile/event/paint"
 	"golang.org/x/mobile/event/size"
@@ -12,7 +14,6 @@ import (
 	"golang.org/x/mobile/exp/app/debug"
 	"golang.org/x/mobile/exp/gl/glutil"
 	"golang.org/x/mobile/exp/sprite"
-	"golang.org/x/mobile/exp/sprite/clock"
 	"golang.org/x/mobile/exp/sprite/glsprite"
 	"golang.org/x/mobile/gl"
 )
@@ -24,14 +25,22 @@ const (
 var (
 	g            *Game
 	windowRadius float64
-	start        = time.Now()
-	lastClock    = clock.Time(-1)
 	images       *glutil.Images
 	eng          sprite.Engine
 	fps          *debug.FPS
+	levelCode    = flag.String("code", "", "level code of a custom level to play")
+	timeAttack   = flag.Bool("timeattack", false, "play against the clock")
+	saveSlot     = flag.Int("slot", -1, "save slot to play with")
+	recordPath   = flag.String("record", "", "file where the replays are saved, suffixed by the level")
+	replayPath   = flag.String("replay", "", "replay file to play")
+	replaySpeed  = flag.Float64("speed", 1, "speed of the replay")
+	queueSize    = flag.Int("queue", DefaultQueueSize, "number of presses queued during a move")
+	fastQueue    = flag.Bool("fastqueue", false, "speed up the queued moves")
+	losePolicy   = flag.String("lose", "", "what happens when a level is lost: restart, life or pack")
 )
 
 func main() {
+	flag.Parse()
 	app.Main(func(a app.App) {
 		var glctx gl.Context
 		var sz size.Event
@@ -43,9 +52,15 @@ func main() {
 					log.Print("lifecycle.Crosson")
 					glctx, _ = e.DrawContext.(gl.Context)
 					onStart(glctx)
+					if g != nil {
+						g.Resume()
+					}
 					a.Send(paint.Event{})
 				case lifecycle.CrossOff:
 					log.Print("lifecycle.CrossOff")
+					if g != nil {
+						g.Stop()
+					}
 					onStop()
 					glctx = nil
 				}
@@ -54,7 +69,35 @@ func main() {
 				sz = e
 				computeSizes(sz)
 				if g == nil {
-					NewGame(glctx)
+					NewGame(glctx, openStore())
+					if *saveSlot >= 0 {
+						if err := g.SelectSlot(*saveSlot); err != nil {
+							log.Println("Can't select the save slot", err)
+						}
+					}
+					if *timeAttack || g.store.Settings.TimeAttack {
+						g.StartTimeAttack()
+					}
+					g.recordPath = *recordPath
+					g.queueSize = *queueSize
+					g.fastQueue = *fastQueue || g.store.Settings.FastQueue
+					if *losePolicy != "" {
+						if p, err := ParseLosePolicy(*losePolicy); err != nil {
+							log.Println(err)
+						} else {
+							g.losePolicy = p
+						}
+					}
+					if *replayPath != "" {
+						if err := playReplayFile(*replayPath, float32(*replaySpeed)); err != nil {
+							log.Println("Can't play the replay", err)
+						}
+					}
+					if *levelCode != "" {
+						if err := g.EnterCode(*levelCode); err != nil {
+							log.Println("Can't load level code", err)
+						}
+					}
 				}
 				initWorld(glctx)
 			case paint.Event:
@@ -70,11 +113,28 @@ func main() {
 				a.Send(paint.Event{})
 			case touch.Event:
 				touch_(sz, e)
+			case key.Event:
+				if g != nil {
+					g.Key(e)
+				}
 			}
 		}
 	})
 }
 
+func playReplayFile(path string, speed float32) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := ParseReplay(f)
+	if err != nil {
+		return err
+	}
+	return g.PlayReplay(r, speed)
+}
+
 func onStart(glctx gl.Context) {
 	images = glutil.NewImages(glctx)
 	fps = debug.NewFPS(images)
@@ -96,11 +156,11 @@ func glInit(glctx gl.Context) {
 }
 
 func draw(glctx gl.Context, sz size.Event) {
-	now := clock.Time(time.Since(start) * FPS / time.Second)
-	if now == lastClock {
+	now, ok := g.sched.Frame()
+	if !ok {
 		return
 	}
-	lastClock = now
+	g.Tick(now)
 
 	glctx.ClearColor(0.9, 0.09, 0.26, 0.0)
 	glctx.Clear(gl.COLOR_BUFFER_BIT)
@@ -110,7 +170,13 @@ func draw(glctx gl.Context, sz size.Event) {
 
 func touch_(sz size.Event, t touch.Event) {
 	log.Printf("TOUCH %+v", t)
-	if t.Type == touch.TypeEnd {
-		g.Click(float32(t.X)/sz.PixelsPerPt, float32(t.Y)/sz.PixelsPerPt)
+	x, y := float32(t.X)/sz.PixelsPerPt, float32(t.Y)/sz.PixelsPerPt
+	switch t.Type {
+	case touch.TypeBegin:
+		g.TouchBegin(x, y)
+	case touch.TypeMove:
+		g.TouchMove(x, y)
+	case touch.TypeEnd:
+		g.TouchEnd(x, y)
 	}
 }
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"log"
+
+	"golang.org/x/mobile/exp/f32"
+)
+
+// levelCount returns the number of levels in the assets.
+func levelCount() int {
+	n := 0
+	for hasLevel(n + 1) {
+		n++
+	}
+	return n
+}
+
+// LoadMenu builds the level select scene, a grid of the level
+// signatures. The locked levels have a lock, and the solved
+// ones the stars of the best score.
+func (w *World) LoadMenu() {
+	w.menu = w.newNode()
+	w.eng.SetTransform(w.menu, f32.Affine{
+		{1, 0, 0},
+		{0, 1, 0},
+	})
+	w.thumbs = nil
+	progress := g.store.Progress()
+	cols := 4
+	if !portrait {
+		cols = 5
+	}
+	n := levelCount()
+	rows := (n + cols - 1) / cols
+	// The buttons are below the grid
+	cell := (windowWidth - padding*2) / float32(cols)
+	if h := (windowHeight - padding*3 - switchSize) / float32(rows); h < cell {
+		cell = h
+	}
+	// The signature takes most of the cell, the stars are below
+	thumbSize := cell * .7
+	starSize := cell * .15
+	for i := 0; i < n; i++ {
+		level := i + 1
+		x := padding + float32(i%cols)*cell
+		y := padding + float32(i/cols)*cell
+		w.thumbs = append(w.thumbs, &Object{X: x, Y: y, Width: cell, Height: cell, Data: level})
+
+		l := LoadLevel(level)
+		thumb := w.newNode()
+		w.menu.AppendChild(thumb)
+		w.eng.SetTransform(thumb, f32.Affine{
+			{1, 0, x + (cell-thumbSize)/2},
+			{0, 1, y + cell*.05},
+		})
+		w.addSignature(thumb, l, thumbSize/4)
+
+		if level > progress.Unlocked {
+			lockSize := thumbSize / 2
+			n := w.newNode()
+			w.menu.AppendChild(n)
+			n.Arranger = &Object{
+				X:      x + (cell-lockSize)/2,
+				Y:      y + cell*.05 + (thumbSize-lockSize)/2,
+				Width:  lockSize,
+				Height: lockSize,
+				Sprite: w.texs[texLock],
+			}
+			continue
+		}
+		best, ok := progress.Best[level]
+		if !ok {
+			continue
+		}
+		for s := 0; s < MaxStars; s++ {
+			tex := texStarEmpty
+			if s < best.Stars {
+				tex = texStarFull
+			}
+			n := w.newNode()
+			w.menu.AppendChild(n)
+			n.Arranger = &Object{
+				X:      x + cell/2 + (float32(s)-float32(MaxStars)/2)*starSize,
+				Y:      y + cell*.8,
+				Width:  starSize,
+				Height: starSize,
+				Sprite: w.texs[tex],
+			}
+		}
+	}
+	w.codeButton = w.newButton(w.menu, padding, windowHeight-padding-switchSize, texCode, func() bool {
+		return true
+	})
+	timeTex := texClockOff
+	if g.timer != nil {
+		timeTex = texClock
+	}
+	w.timeButton = w.newButton(w.menu, windowWidth-padding-switchSize, windowHeight-padding-switchSize, timeTex, func() bool {
+		return true
+	})
+}
+
+// menuLevel returns the level of the thumbnail at
+// the coordinates, 0 if there's none.
+func (w *World) menuLevel(x, y float32) int {
+	for _, o := range w.thumbs {
+		if x >= o.X && x < o.X+o.Width && y >= o.Y && y < o.Y+o.Height {
+			return o.Data.(int)
+		}
+	}
+	return 0
+}
+
+// OpenMenu shows the level select.
+func (g *Game) OpenMenu() {
+	if !g.state.Fire(OnMenu, g.now) {
+		return
+	}
+	g.drag = nil
+	g.longPress = nil
+	g.prompt = nil
+	if g.timer != nil {
+		g.timer.Pause()
+	}
+	if g.world != nil {
+		g.world.LoadMenu()
+	}
+}
+
+// CloseMenu goes back to the level in progress.
+func (g *Game) CloseMenu() {
+	if g.state.State() == Menu {
+		g.state.Fire(OnResume, g.now)
+	}
+}
+
+// SelectLevel plays the level chosen in the level select,
+// if it's unlocked. The level in progress is resumed.
+func (g *Game) SelectLevel(level int) {
+	if level < 1 || level > g.store.Progress().Unlocked || !hasLevel(level) {
+		return
+	}
+	if level == g.currentLevel {
+		g.CloseMenu()
+		return
+	}
+	log.Println("Level selected", level)
+	g.currentLevel = level
+	g.level = LoadLevel(level)
+	g.levelLoaded()
+	g.world.LoadScene()
+}
+
+// ToggleTimeAttack switches the time-attack mode from the
+// level select, the choice is kept in the settings.
+func (g *Game) ToggleTimeAttack() {
+	if g.state.State() != Menu {
+		return
+	}
+	g.store.Settings.TimeAttack = g.timer == nil
+	if g.store.Settings.TimeAttack {
+		g.StartTimeAttack()
+		// Counted from the level resume
+		g.timer.Pause()
+	} else {
+		g.timer = nil
+	}
+	if err := g.store.Save(); err != nil {
+		log.Println("Can't save the settings", err)
+	}
+	if g.world != nil {
+		g.world.LoadScene()
+		g.world.LoadMenu()
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMenuGame(t *testing.T) *stepGame {
+	game := newDragGame(t, LoadLevel(1))
+	game.currentLevel = 1
+	game.store, _ = NewStore(&memStorage{})
+	game.OpenMenu()
+	return game
+}
+
+func TestLevelCount(t *testing.T) {
+	n := levelCount()
+
+	assert.True(t, n > 1)
+	assert.True(t, hasLevel(n))
+	assert.False(t, hasLevel(n+1))
+}
+
+func TestMenuOpen(t *testing.T) {
+	game := newMenuGame(t)
+
+	assert.Equal(t, Menu, game.state.State())
+	assert.Len(t, game.world.thumbs, levelCount())
+	game.frame(func() { game.Press(0, true) })
+	assert.Nil(t, game.level.rotating)
+}
+
+func TestMenuLevel(t *testing.T) {
+	game := newMenuGame(t)
+	o := game.world.thumbs[1]
+
+	assert.Equal(t, 2, game.world.menuLevel(o.X+o.Width/2, o.Y+o.Height/2))
+	assert.Equal(t, 0, game.world.menuLevel(-1, -1))
+}
+
+func TestSelectLockedLevel(t *testing.T) {
+	game := newMenuGame(t)
+
+	game.SelectLevel(2)
+
+	assert.Equal(t, Menu, game.state.State())
+	assert.Equal(t, 1, game.currentLevel)
+}
+
+func TestSelectLevel(t *testing.T) {
+	game := newMenuGame(t)
+	game.store.Progress().Unlocked = 3
+
+	game.SelectLevel(3)
+
+	assert.Equal(t, Intro, game.state.State())
+	assert.Equal(t, 3, game.currentLevel)
+}
+
+func TestSelectCurrentLevel(t *testing.T) {
+	game := newMenuGame(t)
+	game.level.moves = 1
+
+	game.SelectLevel(1)
+
+	assert.Equal(t, Playing, game.state.State())
+	assert.Equal(t, 1, game.level.moves)
+}
+
+func TestCloseMenu(t *testing.T) {
+	game := newMenuGame(t)
+
+	game.CloseMenu()
+	assert.Equal(t, Playing, game.state.State())
+	game.CloseMenu()
+	assert.Equal(t, Playing, game.state.State())
+}
+
+func TestToggleTimeAttack(t *testing.T) {
+	game := newMenuGame(t)
+	o := game.world.timeButton
+
+	game.Click(o.X+switchSize/2, o.Y+switchSize/2)
+
+	assert.True(t, game.store.Settings.TimeAttack)
+	assert.NotNil(t, game.timer)
+	assert.NotNil(t, game.world.timeCounter)
+	assert.Equal(t, texClock, game.world.timeButton.tex)
+
+	game.ToggleTimeAttack()
+
+	assert.False(t, game.store.Settings.TimeAttack)
+	assert.Nil(t, game.timer)
+	assert.Nil(t, game.world.timeCounter)
+}
+
+func TestTimeAttackStartsOnResume(t *testing.T) {
+	game := newMenuGame(t)
+	game.ToggleTimeAttack()
+
+	for i := 0; i < FPS*2; i++ {
+		game.frame(nil)
+	}
+	assert.Equal(t, TimeAttackSeconds, game.timer.Seconds())
+
+	game.CloseMenu()
+	for i := 0; i < FPS*2; i++ {
+		game.frame(nil)
+	}
+	assert.Less(t, game.timer.Seconds(), TimeAttackSeconds)
+}
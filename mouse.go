@@ -0,0 +1,48 @@
+package main
+
+// The app drivers report the mouse as touches, without the
+// buttons, so the right click can't be told from the left
+// one. Shift click replaces it to turn the switches counter
+// clockwise, with the drivers reporting the keys. The x11
+// and macOS drivers only report the moves while a button is
+// held, so the hover only works with the drivers sending the
+// moves of a mouse with no button held.
+
+// hoverScale is the scale of the switch under the mouse.
+const hoverScale = 1.15
+
+// Hover highlights the switch under the mouse and
+// the blocks it turns.
+func (g *Game) Hover(x, y float32) {
+	g.hover = nil
+	if _, s := g.level.findSwitch(x, y); s != nil && g.level.enabled(s) {
+		g.hover = s
+	}
+}
+
+// hovered returns true if the block is turned by
+// the switch under the mouse.
+func (g *Game) hovered(b *Block) bool {
+	if g.hover == nil || g.state.State() != Playing {
+		return false
+	}
+	for _, hb := range g.level.Blocks(g.hover) {
+		if hb == b {
+			return true
+		}
+	}
+	return false
+}
+
+// ShiftClick presses the switch counter clockwise.
+func (g *Game) ShiftClick(x, y float32) {
+	if g.playback != nil {
+		return
+	}
+	if state := g.state.State(); state != Playing && state != Rotating {
+		return
+	}
+	if i, s := g.level.findSwitch(x, y); s != nil && s.dir != Clockwise {
+		g.Press(i, false)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mobile/event/key"
+)
+
+func TestHover(t *testing.T) {
+	game := newDragGame(t, ParseLevel(codeLevel))
+	s := game.level.switches[1]
+
+	game.Hover(s.X+switchSize/2, s.Y+switchSize/2)
+
+	assert.Equal(t, s, game.hover)
+	for _, b := range game.level.Blocks(s) {
+		assert.True(t, game.hovered(b))
+	}
+	assert.False(t, game.hovered(game.level.blocks[0][2]))
+	game.frame(nil)
+	assert.Equal(t, float32(hoverScale), s.Scale)
+}
+
+func TestHoverOutside(t *testing.T) {
+	game := newDragGame(t, ParseLevel(codeLevel))
+	s := game.level.switches[1]
+	game.Hover(s.X+switchSize/2, s.Y+switchSize/2)
+
+	game.Hover(0, 0)
+
+	assert.Nil(t, game.hover)
+	game.frame(nil)
+	assert.Equal(t, float32(0), s.Scale)
+}
+
+func TestHoverDuringMove(t *testing.T) {
+	game := newDragGame(t, ParseLevel(codeLevel))
+	s := game.level.switches[1]
+	game.Hover(s.X+switchSize/2, s.Y+switchSize/2)
+
+	game.Press(1, true)
+
+	assert.False(t, game.hovered(game.level.Blocks(s)[0]))
+}
+
+func TestHoverMouseMove(t *testing.T) {
+	game := newDragGame(t, ParseLevel(codeLevel))
+	s := game.level.switches[1]
+	x, y := s.X+switchSize/2, s.Y+switchSize/2
+
+	game.TouchMove(x, y)
+	assert.Equal(t, s, game.hover)
+	// The moves of a touch don't hover
+	game.TouchBegin(0, 0)
+	game.TouchMove(x, y)
+	game.TouchMove(1, 1)
+
+	assert.Equal(t, s, game.hover)
+}
+
+// bothWaysLevel is codeLevel with the switch 1,0
+// turning both ways.
+var bothWaysLevel = strings.Replace(codeLevel, "\n1,0\n", "\n1,0,both\n", 1)
+
+func TestShiftClick(t *testing.T) {
+	l := ParseLevel(bothWaysLevel)
+	expected := l.Copy()
+	expected.RotateSwitch(expected.switches[1], false)
+	game := newDragGame(t, l)
+	s := game.level.switches[1]
+	assert.Equal(t, BothWays, s.dir)
+	// Even on the right half of the switch
+	x, y := s.X+switchSize*.9, s.Y+switchSize/2
+
+	game.Key(key.Event{Code: key.CodeLeftShift, Direction: key.DirPress})
+	game.TouchBegin(x, y)
+	game.TouchEnd(x, y)
+
+	assert.Equal(t, Move{{sw: 1, clockwise: false}}, game.level.rotating)
+	game.settle()
+	assert.Equal(t, expected.blockSignature(), game.level.blockSignature())
+	game.Key(key.Event{Code: key.CodeLeftShift, Direction: key.DirRelease})
+	assert.False(t, game.shift)
+}
+
+func TestClickBothWays(t *testing.T) {
+	game := newDragGame(t, ParseLevel(bothWaysLevel))
+	s := game.level.switches[1]
+
+	// Without shift, the right half turns clockwise
+	game.TouchBegin(s.X+switchSize*.9, s.Y+switchSize/2)
+	game.TouchEnd(s.X+switchSize*.9, s.Y+switchSize/2)
+
+	assert.Equal(t, Move{{sw: 1, clockwise: true}}, game.level.rotating)
+}
+
+func TestShiftClickClockwiseOnly(t *testing.T) {
+	game := newDragGame(t, ParseLevel(codeLevel))
+	s := game.level.switches[1]
+
+	game.ShiftClick(s.X+switchSize/2, s.Y+switchSize/2)
+
+	assert.Nil(t, game.level.rotating)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"golang.org/x/mobile/exp/f32"
+	"golang.org/x/mobile/exp/sprite"
+	"golang.org/x/mobile/exp/sprite/clock"
+)
+
+// codeAlphabet is the set of the level code characters,
+// in the order of their textures.
+const codeAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// maxCodeLen bounds the text of the code prompt, far
+// above the code of the largest level.
+const maxCodeLen = 200
+
+// codePrompt is the level code typed in the level select.
+// The mobile drivers have neither a soft keyboard nor a
+// clipboard, so the code is typed on on-screen keys.
+type codePrompt struct {
+	text []byte
+	// failed is the time the code was refused,
+	// the text shakes for a while.
+	failed clock.Time
+}
+
+// OpenPrompt shows the code prompt over the level select.
+func (g *Game) OpenPrompt() {
+	if g.state.State() != Menu {
+		return
+	}
+	g.prompt = &codePrompt{}
+	if g.world != nil {
+		g.world.LoadPrompt()
+	}
+}
+
+// ClosePrompt goes back to the level select.
+func (g *Game) ClosePrompt() {
+	g.prompt = nil
+}
+
+// TypeCode appends the character to the code,
+// the ones out of the code alphabet are ignored.
+func (g *Game) TypeCode(c byte) {
+	if g.prompt == nil || strings.IndexByte(codeAlphabet, c) < 0 || len(g.prompt.text) >= maxCodeLen {
+		return
+	}
+	g.prompt.text = append(g.prompt.text, c)
+	g.prompt.failed = 0
+}
+
+// EraseCode removes the last character of the code.
+func (g *Game) EraseCode() {
+	if g.prompt == nil || len(g.prompt.text) == 0 {
+		return
+	}
+	g.prompt.text = g.prompt.text[:len(g.prompt.text)-1]
+	g.prompt.failed = 0
+}
+
+// SubmitCode plays the custom level of the typed code.
+// The prompt stays open if the code is invalid.
+func (g *Game) SubmitCode() {
+	if g.prompt == nil {
+		return
+	}
+	if err := g.EnterCode(string(g.prompt.text)); err != nil {
+		log.Println("Invalid level code", err)
+		g.prompt.failed = g.now
+		return
+	}
+	g.prompt = nil
+}
+
+// LoadPrompt builds the code prompt scene, the typed code
+// on top, the keys of the code alphabet and the buttons
+// to go back, erase a character and play the code.
+func (w *World) LoadPrompt() {
+	w.prompt = w.newNode()
+	w.eng.SetTransform(w.prompt, f32.Affine{
+		{1, 0, 0},
+		{0, 1, 0},
+	})
+	w.keys = nil
+	cols := 8
+	if !portrait {
+		cols = 16
+	}
+	rows := (len(codeAlphabet) + cols - 1) / cols
+	// A line for the code and one for the buttons
+	cell := (windowWidth - padding*2) / float32(cols)
+	if h := (windowHeight - padding*2) / float32(rows+2); h < cell {
+		cell = h
+	}
+	left := (windowWidth - cell*float32(cols)) / 2
+	top := (windowHeight - cell*float32(rows+2)) / 2
+
+	// The code, only its end is displayed if it's too long
+	glyphHeight := cell * .6
+	glyphWidth := glyphHeight * TexGlyphWidth / TexGlyphHeight
+	nbChar := int(cell * float32(cols) / glyphWidth)
+	for i := 0; i < nbChar; i++ {
+		n := w.newNode()
+		w.prompt.AppendChild(n)
+		n.Arranger = &Object{
+			X:      left + float32(i)*glyphWidth,
+			Y:      top + (cell-glyphHeight)/2,
+			Width:  glyphWidth,
+			Height: glyphHeight,
+			Data:   nbChar - i,
+			Action: ActionFunc(promptChar),
+		}
+	}
+
+	// The keys
+	keyHeight := cell * .7
+	keyWidth := keyHeight * TexGlyphWidth / TexGlyphHeight
+	for i := 0; i < len(codeAlphabet); i++ {
+		x := left + float32(i%cols)*cell
+		y := top + float32(i/cols+1)*cell
+		w.keys = append(w.keys, &Object{X: x, Y: y, Width: cell, Height: cell, Data: codeAlphabet[i]})
+
+		n := w.newNode()
+		w.prompt.AppendChild(n)
+		n.Arranger = &Object{
+			X:      x + (cell-keyWidth)/2,
+			Y:      y + (cell-keyHeight)/2,
+			Width:  keyWidth,
+			Height: keyHeight,
+			Sprite: w.glyph(i),
+		}
+	}
+
+	// The buttons
+	buttonY := top + float32(rows+1)*cell + (cell-switchSize)/2
+	w.backButton = w.newButton(w.prompt, left, buttonY, texMenu, func() bool {
+		return true
+	})
+	w.eraseButton = w.newButton(w.prompt, windowWidth/2-switchSize/2, buttonY, texErase, func() bool {
+		return g.prompt != nil && len(g.prompt.text) > 0
+	})
+	w.okButton = w.newButton(w.prompt, windowWidth-left-switchSize, buttonY, texOk, func() bool {
+		return g.prompt != nil && len(g.prompt.text) > 0
+	})
+}
+
+// promptKey returns the character of the key at
+// the coordinates, 0 if there's none.
+func (w *World) promptKey(x, y float32) byte {
+	for _, o := range w.keys {
+		if x >= o.X && x < o.X+o.Width && y >= o.Y && y < o.Y+o.Height {
+			return o.Data.(byte)
+		}
+	}
+	return 0
+}
+
+// glyph returns the texture of the code character
+// at the index in the code alphabet.
+func (w *World) glyph(i int) sprite.SubTex {
+	if i < 0 || i >= len(w.glyphs) {
+		return w.texs[texEmpty]
+	}
+	return w.glyphs[i]
+}
+
+// clickPrompt handles the clicks on the code prompt.
+func (g *Game) clickPrompt(x, y float32) {
+	switch {
+	case g.world.backButton.hit(x, y):
+		g.ClosePrompt()
+
+	case g.world.eraseButton.hit(x, y):
+		g.EraseCode()
+
+	case g.world.okButton.hit(x, y):
+		g.SubmitCode()
+
+	default:
+		if c := g.world.promptKey(x, y); c != 0 {
+			g.TypeCode(c)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mobile/event/key"
+)
+
+func newPromptGame(t *testing.T) *stepGame {
+	game := newMenuGame(t)
+	game.Click(game.world.codeButton.X+switchSize/2, game.world.codeButton.Y+switchSize/2)
+	return game
+}
+
+// clickKey clicks the key of the character.
+func clickKey(game *stepGame, c byte) {
+	for _, o := range game.world.keys {
+		if o.Data.(byte) == c {
+			game.Click(o.X+o.Width/2, o.Y+o.Height/2)
+		}
+	}
+}
+
+func TestPromptOpen(t *testing.T) {
+	game := newPromptGame(t)
+
+	assert.NotNil(t, game.prompt)
+	assert.Len(t, game.world.keys, len(codeAlphabet))
+	assert.Equal(t, Menu, game.state.State())
+}
+
+func TestPromptTypeKeys(t *testing.T) {
+	game := newPromptGame(t)
+
+	clickKey(game, 'a')
+	clickKey(game, '_')
+	clickKey(game, '7')
+
+	assert.Equal(t, "a_7", string(game.prompt.text))
+	game.Click(game.world.eraseButton.X+switchSize/2, game.world.eraseButton.Y+switchSize/2)
+	assert.Equal(t, "a_", string(game.prompt.text))
+}
+
+func TestPromptIgnoresOtherChars(t *testing.T) {
+	game := newPromptGame(t)
+
+	game.TypeCode('=')
+	game.Key(key.Event{Code: key.CodeSpacebar, Rune: ' ', Direction: key.DirPress})
+
+	assert.Empty(t, game.prompt.text)
+}
+
+func TestPromptSubmit(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	code, err := EncodeLevel(l)
+	assert.NoError(t, err)
+	game := newPromptGame(t)
+
+	for _, c := range code {
+		game.Key(key.Event{Rune: c, Direction: key.DirPress})
+	}
+	game.Key(press(key.CodeReturnEnter, 0))
+
+	assert.Nil(t, game.prompt)
+	assert.Equal(t, Intro, game.state.State())
+	assert.Equal(t, 0, game.currentLevel)
+	assert.Equal(t, code, game.code)
+	assert.Equal(t, l.blockSignature(), game.level.blockSignature())
+}
+
+func TestPromptInvalidCode(t *testing.T) {
+	game := newPromptGame(t)
+
+	clickKey(game, 'A')
+	game.Click(game.world.okButton.X+switchSize/2, game.world.okButton.Y+switchSize/2)
+
+	assert.NotNil(t, game.prompt)
+	assert.NotZero(t, game.prompt.failed)
+	assert.Equal(t, Menu, game.state.State())
+	assert.Equal(t, 1, game.currentLevel)
+}
+
+func TestPromptEscape(t *testing.T) {
+	game := newPromptGame(t)
+
+	game.Key(press(key.CodeEscape, 0))
+
+	assert.Nil(t, game.prompt)
+	assert.Equal(t, Menu, game.state.State())
+}
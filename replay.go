@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mobile/exp/sprite/clock"
+)
+
+// Replays are text files, used to reproduce a game exactly :
+//
+//	mozaik replay 1
+//	level 3
+//	0 p 2
+//	35 p 1'
+//	80 u
+//	95 r
+//	120 j 0
+//
+// The level line is replaced by "code <level code>" for the
+// custom levels. Each event starts with its time in frames since
+// the level start, followed by the event kind. The switch presses
+// have the switch index, followed by a quote if counter clockwise.
+// The history jumps have the move count reached.
+const replayHeader = "mozaik replay 1"
+
+// EventKind is the kind of player action in a replay.
+type EventKind byte
+
+const (
+	EventPress EventKind = 'p'
+	EventUndo  EventKind = 'u'
+	EventRedo  EventKind = 'r'
+	EventJump  EventKind = 'j'
+)
+
+// ReplayEvent is a player action.
+type ReplayEvent struct {
+	Time      clock.Time
+	Kind      EventKind
+	Sw        int
+	Clockwise bool
+	// Move is the move count reached by a jump
+	Move int
+}
+
+// Replay is the record of the player actions on a level.
+type Replay struct {
+	Level  int
+	Code   string
+	Events []ReplayEvent
+}
+
+func (e ReplayEvent) String() string {
+	switch e.Kind {
+	case EventJump:
+		return fmt.Sprintf("%d %c %d", e.Time, e.Kind, e.Move)
+	case EventUndo, EventRedo:
+		return fmt.Sprintf("%d %c", e.Time, e.Kind)
+	}
+	s := fmt.Sprintf("%d %c %d", e.Time, e.Kind, e.Sw)
+	if !e.Clockwise {
+		s += "'"
+	}
+	return s
+}
+
+// WriteTo writes the replay file.
+func (r *Replay) WriteTo(w io.Writer) (int64, error) {
+	lines := []string{replayHeader}
+	if r.Level == 0 {
+		lines = append(lines, "code "+r.Code)
+	} else {
+		lines = append(lines, fmt.Sprintf("level %d", r.Level))
+	}
+	for _, e := range r.Events {
+		lines = append(lines, e.String())
+	}
+	n, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return int64(n), err
+}
+
+// ParseReplay reads a replay file.
+func ParseReplay(rd io.Reader) (*Replay, error) {
+	r := &Replay{}
+	sc := bufio.NewScanner(rd)
+	if !sc.Scan() || sc.Text() != replayHeader {
+		return nil, errors.New("not a replay file")
+	}
+	for i := 2; sc.Scan(); i++ {
+		tokens := strings.Fields(sc.Text())
+		if len(tokens) == 0 {
+			continue
+		}
+		if len(tokens) != 2 && len(tokens) != 3 {
+			return nil, fmt.Errorf("malformed replay line %d", i)
+		}
+		var err error
+		switch tokens[0] {
+		case "level":
+			r.Level, err = strconv.Atoi(tokens[1])
+		case "code":
+			r.Code = tokens[1]
+		default:
+			var e ReplayEvent
+			e, err = parseEvent(tokens)
+			r.Events = append(r.Events, e)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed replay line %d: %v", i, err)
+		}
+	}
+	return r, sc.Err()
+}
+
+func parseEvent(tokens []string) (ReplayEvent, error) {
+	var e ReplayEvent
+	t, err := strconv.Atoi(tokens[0])
+	if err != nil {
+		return e, err
+	}
+	e.Time = clock.Time(t)
+	e.Kind = EventKind(tokens[1][0])
+	switch {
+	case len(tokens[1]) != 1:
+		return e, fmt.Errorf("unknown event %q", tokens[1])
+	case e.Kind == EventUndo || e.Kind == EventRedo:
+		if len(tokens) != 2 {
+			return e, errors.New("unexpected switch")
+		}
+		return e, nil
+	case e.Kind == EventJump && len(tokens) == 3:
+		e.Move, err = strconv.Atoi(tokens[2])
+		return e, err
+	case e.Kind != EventPress || len(tokens) != 3:
+		return e, fmt.Errorf("unknown event %q", tokens[1])
+	}
+	sw := strings.TrimSuffix(tokens[2], "'")
+	e.Clockwise = sw == tokens[2]
+	e.Sw, err = strconv.Atoi(sw)
+	return e, err
+}
+
+// startReplay starts the record of the current level. The moves
+// of a resumed level are recorded at the level start.
+func (g *Game) startReplay() {
+	g.saveReplay()
+	g.replay = &Replay{Level: g.currentLevel}
+	if g.currentLevel == 0 {
+		g.replay.Code = g.code
+	}
+	g.replayStart = g.now
+	for _, m := range g.level.rotated {
+		g.replay.Events = append(g.replay.Events, ReplayEvent{Kind: EventPress, Sw: m[0].sw, Clockwise: m[0].clockwise})
+	}
+}
+
+// record appends the player action to the replay.
+func (g *Game) record(e ReplayEvent) {
+	if g.replay == nil {
+		return
+	}
+	e.Time = g.now - g.replayStart
+	g.replay.Events = append(g.replay.Events, e)
+}
+
+// replayPath returns the file of the replay, the record
+// path with the level number, or the level code for the
+// custom levels, before the extension.
+func (g *Game) replayPath(r *Replay) string {
+	ext := filepath.Ext(g.recordPath)
+	name := strconv.Itoa(r.Level)
+	if r.Level == 0 {
+		name = r.Code
+	}
+	return strings.TrimSuffix(g.recordPath, ext) + "-" + name + ext
+}
+
+// saveReplay writes the replay file, when the
+// record is enabled.
+func (g *Game) saveReplay() {
+	if g.recordPath == "" || g.replay == nil || len(g.replay.Events) == 0 {
+		return
+	}
+	f, err := os.Create(g.replayPath(g.replay))
+	if err != nil {
+		log.Println("Can't save the replay", err)
+		return
+	}
+	defer f.Close()
+	if _, err := g.replay.WriteTo(f); err != nil {
+		log.Println("Can't save the replay", err)
+	}
+}
+
+// PlayReplay loads the level of the replay and plays its events,
+// speed accelerates the playback.
+func (g *Game) PlayReplay(r *Replay, speed float32) error {
+	if r.Level == 0 {
+		if err := g.EnterCode(r.Code); err != nil {
+			return err
+		}
+	} else {
+		if !hasLevel(r.Level) {
+			return fmt.Errorf("unknown level %d", r.Level)
+		}
+		g.currentLevel = r.Level
+		g.level = LoadLevel(r.Level)
+		g.levelLoaded()
+		if g.world != nil {
+			g.world.LoadScene()
+		}
+	}
+	g.replay = nil
+	g.playback = r
+	g.playbackNext = 0
+	g.playbackSpeed = speed
+	g.replayStart = g.now
+	log.Printf("Replay of level %d started", r.Level)
+	return nil
+}
+
+// stepPlayback applies the events of the replay which are due.
+// The next event waits until the previous move is done.
+func (g *Game) stepPlayback() {
+	r := g.playback
+	if r == nil {
+		return
+	}
+	for g.playbackNext < len(r.Events) && g.state.State() == Playing && g.level.rotating == nil {
+		e := r.Events[g.playbackNext]
+		if float32(g.now-g.replayStart)*g.playbackSpeed < float32(e.Time) {
+			return
+		}
+		g.apply(e)
+		g.playbackNext++
+	}
+	if g.playbackNext == len(r.Events) {
+		log.Println("Replay over")
+		g.playback = nil
+	}
+}
+
+// apply plays the event on the current level.
+func (g *Game) apply(e ReplayEvent) {
+	switch e.Kind {
+	case EventPress:
+		if e.Sw >= 0 && e.Sw < len(g.level.switches) && g.level.enabled(g.level.switches[e.Sw]) {
+			g.level.triggerSwitch(e.Sw, e.Clockwise)
+		}
+	case EventUndo:
+		g.level.UndoLastMove()
+	case EventRedo:
+		g.level.Redo()
+	case EventJump:
+		g.level.JumpTo(e.Move)
+		// Start the first move right now, so the
+		// next actions wait for the jump.
+		g.level.stepJump()
+	}
+	if g.level.rotating != nil {
+		g.state.Fire(OnMove, g.now)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mobile/exp/sprite/clock"
+)
+
+const replayFile = `mozaik replay 1
+level 3
+0 p 2
+35 p 1'
+80 u
+95 r
+120 j 0
+`
+
+func TestParseReplay(t *testing.T) {
+	r, err := ParseReplay(strings.NewReader(replayFile))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, r.Level)
+	assert.Equal(t, []ReplayEvent{
+		{Time: 0, Kind: EventPress, Sw: 2, Clockwise: true},
+		{Time: 35, Kind: EventPress, Sw: 1},
+		{Time: 80, Kind: EventUndo},
+		{Time: 95, Kind: EventRedo},
+		{Time: 120, Kind: EventJump},
+	}, r.Events)
+}
+
+func TestWriteReplay(t *testing.T) {
+	r, _ := ParseReplay(strings.NewReader(replayFile))
+	var buf bytes.Buffer
+
+	_, err := r.WriteTo(&buf)
+
+	assert.Nil(t, err)
+	assert.Equal(t, replayFile, buf.String())
+}
+
+func TestReplayPath(t *testing.T) {
+	game := &Game{recordPath: "/tmp/game.replay"}
+
+	assert.Equal(t, "/tmp/game-3.replay", game.replayPath(&Replay{Level: 3}))
+	assert.Equal(t, "/tmp/game-abc.replay", game.replayPath(&Replay{Code: "abc"}))
+	game.recordPath = "game"
+	assert.Equal(t, "game-12", game.replayPath(&Replay{Level: 12}))
+}
+
+func TestParseReplayMalformed(t *testing.T) {
+	for _, txt := range []string{
+		"level 3",
+		replayHeader + "\n10 x",
+		replayHeader + "\n10 u 2",
+		replayHeader + "\n10 j",
+		replayHeader + "\nten p 2",
+		replayHeader + "\n10 p two",
+	} {
+		_, err := ParseReplay(strings.NewReader(txt))
+
+		assert.NotNil(t, err, txt)
+	}
+}
+
+func TestRecordAndPlayback(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	code, _ := EncodeLevel(l)
+	game := &Game{level: l, code: code, state: StateMachine{state: Playing}}
+	game.startReplay()
+	game.now = 10
+	game.Press(2, true)
+	game.level.applyRotating()
+	game.Tick(game.now)
+	game.now = 30
+	game.Undo()
+	game.level.applyRotating()
+	game.Tick(game.now)
+	game.now = 50
+	game.Press(1, true)
+	game.level.applyRotating()
+	game.Tick(game.now)
+	expected := game.level.blockSignature()
+
+	replay := game.replay
+	game.level = ParseLevel(codeLevel)
+	game.playback, game.playbackNext, game.playbackSpeed = replay, 0, 2
+	game.replayStart = game.now
+	for i := 0; game.playback != nil && i < 100; i++ {
+		game.now++
+		game.Tick(game.now)
+		game.level.applyRotating()
+	}
+
+	assert.Equal(t, 3, len(replay.Events))
+	assert.Equal(t, clock.Time(30), replay.Events[1].Time)
+	assert.Equal(t, expected, game.level.blockSignature())
+}
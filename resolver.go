@@ -14,15 +14,31 @@ const (
 
 var (
 	signs map[string]bool
-	lvl   Level
+	lvl   *Level
 )
 
 type Board [4][4]Color
 
 // IsPlain returns true if all the blocks of the switch
 // have the same color
-func (b Board) isPlain(li, col int) bool {
-	return b[li][col] == b[li+1][col] && b[li+1][col] == b[li][col+1] && b[li][col+1] == b[li+1][col+1]
+func (b Board) isPlain(ring []cell) bool {
+	for _, c := range ring[1:] {
+		if b[c.line][c.col] != b[ring[0].line][ring[0].col] {
+			return false
+		}
+	}
+	return true
+}
+
+// isPlainMove returns true if all the switches of
+// the move are plain.
+func (b Board) isPlainMove(m Move) bool {
+	for _, r := range m {
+		if !b.isPlain(lvl.ring(lvl.switches[r.sw])) {
+			return false
+		}
+	}
+	return true
 }
 
 func (b *Board) cp(board Board) {
@@ -34,22 +50,35 @@ func (b *Board) cp(board Board) {
 }
 
 func (b Board) win() bool {
-	for i := 0; i < 4; i++ {
-		for j := 0; j < 4; j++ {
-			if lvl.winSignature[i][j] != b[i][j] {
-				return false
-			}
-		}
+	return lvl.goal.Reached(b.colors())
+}
+
+// colors returns the board blocks as the level colors,
+// the board may be larger than the level.
+func (b *Board) colors() [][]Color {
+	colors := make([][]Color, len(lvl.blocks))
+	for i := range colors {
+		colors[i] = b[i][:len(lvl.blocks[i])]
 	}
-	return true
+	return colors
 }
 
-func (b *Board) rotate(li, co int) {
-	color := b[li][co]
-	b[li][co] = b[li+1][co]
-	b[li+1][co] = b[li+1][co+1]
-	b[li+1][co+1] = b[li][co+1]
-	b[li][co+1] = color
+func (b *Board) rotate(ring []cell, clockwise bool) {
+	n := len(ring)
+	if clockwise {
+		last := ring[n-1]
+		color := b[last.line][last.col]
+		for i := n - 1; i > 0; i-- {
+			b[ring[i].line][ring[i].col] = b[ring[i-1].line][ring[i-1].col]
+		}
+		b[ring[0].line][ring[0].col] = color
+	} else {
+		color := b[ring[0].line][ring[0].col]
+		for i := 0; i < n-1; i++ {
+			b[ring[i].line][ring[i].col] = b[ring[i+1].line][ring[i+1].col]
+		}
+		b[ring[n-1].line][ring[n-1].col] = color
+	}
 }
 
 func (b Board) signature() string {
@@ -63,22 +92,6 @@ func (b Board) signature() string {
 	return signature.String()
 }
 
-func (b Board) findManhattan(x, y int) int {
-	c := b[x][y]
-	max := 0
-	for i := 0; i < 4; i++ {
-		for j := 0; j < 4; j++ {
-			if c == lvl.winSignature[i][j] {
-				m := manhattan(x, y, i, j)
-				if m > max {
-					max = m
-				}
-			}
-		}
-	}
-	return max
-}
-
 func abs(x int) int {
 	if x < 0 {
 		return -x
@@ -86,20 +99,22 @@ func abs(x int) int {
 	return x
 }
 
+// hexDistance is the distance of the hexagonal boards, where
+// the odd lines are shifted to the right.
+func hexDistance(x1, y1, x2, y2 int) int {
+	// Use the cube coordinates
+	q1, q2 := y1-(x1-x1%2)/2, y2-(x2-x2%2)/2
+	dq, dr := q1-q2, x1-x2
+	ds := -dq - dr
+	return (abs(dq) + abs(dr) + abs(ds)) / 2
+}
+
 func manhattan(x1, y1, x2, y2 int) int {
 	return abs(x1-x2) + abs(y1-y2)
 }
 
 func (b Board) howFar() int {
-	howfar := 0
-	for i := 0; i < 4; i++ {
-		for j := 0; j < 4; j++ {
-			if lvl.winSignature[i][j] != b[i][j] {
-				howfar += b.findManhattan(i, j)
-			}
-		}
-	}
-	return howfar
+	return lvl.goal.Distance(b.colors())
 }
 
 type Nodes []*Node
@@ -133,11 +148,24 @@ type Node struct {
 	board Board
 	depth int
 	// current switch
-	s        int
+	s int
+	// direction of the current switch rotation
+	clockwise bool
+	// uses holds the remaining uses of the switches,
+	// nil if no switch has limited uses.
+	uses     []int
 	parent   *Node
 	priority int
 }
 
+// key returns the signature of the node state.
+func (n *Node) key() string {
+	if n.uses == nil {
+		return n.board.signature()
+	}
+	return fmt.Sprintf("%s%v", n.board.signature(), n.uses)
+}
+
 func (n *Node) String() string {
 	//return fmt.Sprintf("s%d, d=%d, childs=%+v", n.s, n.depth, n.childs)
 	//return fmt.Sprintf("s%d, d=%d, parent=[%+v] win=%t", n.s, n.depth, n.parent, n.lvl.Win())
@@ -145,20 +173,28 @@ func (n *Node) String() string {
 	return fmt.Sprintf("d=%d, p=%d, sws=%s", depth, n.priority, n.road())
 }
 
-// Returns the switch combination used so far
+// Returns the switch combination used so far.
+// Counter clockwise rotations are followed by a quote.
 func (n *Node) road() string {
 	var s string
 	for n.parent != nil && n.s >= 0 {
-		s = lvl.switches[n.s].name + s
+		s = n.move() + s
 		n = n.parent
 	}
 	if n.s >= 0 {
-		s = lvl.switches[n.s].name + s
+		s = n.move() + s
 	}
 	return s
 }
 
-func Resolve(l Level) *Node {
+func (n *Node) move() string {
+	if n.clockwise {
+		return lvl.switches[n.s].name
+	}
+	return lvl.switches[n.s].name + "'"
+}
+
+func Resolve(l *Level) *Node {
 	f, err := os.Create("resolver.prof")
 	if err != nil {
 		panic(err)
@@ -176,20 +212,29 @@ func Resolve(l Level) *Node {
 			init.board[i][j] = lvl.blocks[i][j].Color
 		}
 	}
+	for i, sw := range lvl.switches {
+		if sw.uses > 0 {
+			if init.uses == nil {
+				init.uses = make([]int, len(lvl.switches))
+			}
+			init.uses[i] = sw.remaining
+		}
+	}
 	init.priority = init.board.howFar()
 	heap.Push(&ns, init)
 	//fmt.Println("INIT NODE", init)
 	signs = make(map[string]bool)
-	signs[init.board.signature()] = true
+	signs[init.key()] = true
 
 	loop := 0
-	for {
+	for ns.Len() > 0 {
 		n := process(&ns)
 		if n != nil {
 			return n
 		}
 		loop++
 	}
+	// No solution
 	return nil
 }
 
@@ -202,31 +247,69 @@ func process(ns *Nodes) *Node {
 		return n
 	}
 	for i, sw := range lvl.switches {
-		if n.board.isPlain(sw.line, sw.col) {
-			// Useless to rotate a plain switch
+		ring := lvl.ring(sw)
+		if len(ring) < 2 {
+			// Disabled by the locked blocks
 			continue
 		}
-		if n.s == i && n.parent != nil && n.parent.s == i && n.parent.parent != nil && n.parent.parent.s == i {
-			// Useless to rotate 4 times in a row the same switch
+		if sw.uses > 0 && n.uses[i] == 0 {
+			// No more uses
 			continue
 		}
+		for _, clockwise := range sw.directions() {
+			m := lvl.move(i, clockwise)
+			if n.board.isPlainMove(m) {
+				// Useless to rotate plain switches
+				continue
+			}
+			if len(m) == 1 {
+				if n.s == i && n.clockwise != clockwise {
+					// Useless to cancel the previous rotation
+					continue
+				}
+				if n.repeats(i, clockwise) == len(ring)-1 {
+					// Useless to rotate a full turn in a row the same switch
+					continue
+				}
+			}
 
-		nn := &Node{
-			s:      i,
-			depth:  n.depth + 1,
-			parent: n,
-		}
-		nn.board.cp(n.board)
-		nn.board.rotate(sw.line, sw.col)
-		sign := nn.board.signature()
-		if _, ok := signs[sign]; ok {
-			// Already processed skip
-			continue
-		}
-		signs[sign] = true
-		nn.priority = nn.board.howFar() + nn.depth
+			nn := &Node{
+				s:         i,
+				clockwise: clockwise,
+				depth:     n.depth + 1,
+				parent:    n,
+			}
+			nn.board.cp(n.board)
+			for _, r := range m {
+				nn.board.rotate(lvl.ring(lvl.switches[r.sw]), r.clockwise)
+			}
+			if n.uses != nil {
+				nn.uses = make([]int, len(n.uses))
+				copy(nn.uses, n.uses)
+				if sw.uses > 0 {
+					nn.uses[i]--
+				}
+			}
+			sign := nn.key()
+			if _, ok := signs[sign]; ok {
+				// Already processed skip
+				continue
+			}
+			signs[sign] = true
+			nn.priority = nn.board.howFar() + nn.depth
 
-		heap.Push(ns, nn)
+			heap.Push(ns, nn)
+		}
 	}
 	return nil
 }
+
+// repeats returns how many times in a row the rotation in
+// parameter leads to the node.
+func (n *Node) repeats(s int, clockwise bool) int {
+	count := 0
+	for ; n != nil && n.s == s && n.clockwise == clockwise; n = n.parent {
+		count++
+	}
+	return count
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestPaths_Level1_(t *testing.T) {
@@ -155,3 +157,20 @@ func TestPaths_Level15(t *testing.T) {
 	d := time.Now().Sub(t0)
 	fmt.Printf("Level15 (%s) %+v\n", d, n)
 }
+
+func TestPaths_Level16(t *testing.T) {
+	lvl := LoadLevel(16)
+	t0 := time.Now()
+
+	n := Resolve(lvl)
+
+	d := time.Now().Sub(t0)
+	fmt.Printf("Level16 (%s) %+v\n", d, n)
+	if !assert.NotNil(t, n) {
+		return
+	}
+	// The hex board is won by the road found
+	l := LoadLevel(16)
+	assert.NoError(t, playMoves(l, n.road()))
+	assert.True(t, l.Win())
+}
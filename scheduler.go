@@ -0,0 +1,62 @@
+package main
+
+import (
+	"time"
+
+	"golang.org/x/mobile/exp/sprite/clock"
+)
+
+// Clock gives the time of the current frame.
+type Clock interface {
+	Now() clock.Time
+}
+
+// wallClock counts the frames elapsed since start.
+type wallClock struct {
+	start time.Time
+}
+
+func (c wallClock) Now() clock.Time {
+	return clock.Time(time.Since(c.start) * FPS / time.Second)
+}
+
+// StepClock is a clock moved forward manually,
+// one frame at a time.
+type StepClock struct {
+	t clock.Time
+}
+
+func (c *StepClock) Now() clock.Time {
+	return c.t
+}
+
+// Step moves to the next frame.
+func (c *StepClock) Step() {
+	c.t++
+}
+
+// Scheduler follows the clock frame after frame.
+type Scheduler struct {
+	clock Clock
+	now   clock.Time
+}
+
+func NewScheduler(c Clock) *Scheduler {
+	return &Scheduler{clock: c, now: -1}
+}
+
+// Frame moves to the current time of the clock. It returns
+// false if the clock didn't move since the previous frame.
+func (s *Scheduler) Frame() (clock.Time, bool) {
+	now := s.clock.Now()
+	if now == s.now {
+		return now, false
+	}
+	s.now = now
+	return now, true
+}
+
+// Now returns the time of the last frame.
+func (s *Scheduler) Now() clock.Time {
+	return s.now
+}
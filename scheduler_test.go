@@ -0,0 +1,91 @@
+package main
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mobile/event/size"
+	"golang.org/x/mobile/exp/f32"
+	"golang.org/x/mobile/exp/sprite"
+	"golang.org/x/mobile/exp/sprite/clock"
+)
+
+// testEngine arranges the scene without drawing anything.
+type testEngine struct{}
+
+func (testEngine) Register(n *sprite.Node)                         {}
+func (testEngine) Unregister(n *sprite.Node)                       {}
+func (testEngine) LoadTexture(image.Image) (sprite.Texture, error) { return nil, nil }
+func (testEngine) SetSubTex(n *sprite.Node, x sprite.SubTex)       {}
+func (testEngine) SetTransform(n *sprite.Node, m f32.Affine)       {}
+func (testEngine) Release()                                        {}
+
+func (e testEngine) Render(n *sprite.Node, t clock.Time, sz size.Event) {
+	if n.Arranger != nil {
+		n.Arranger.Arrange(e, n, t)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		e.Render(c, t, sz)
+	}
+}
+
+// stepGame plays a level frame by frame, from the
+// end of the level intro.
+type stepGame struct {
+	*Game
+	clock *StepClock
+}
+
+func newStepGame(l *Level) *stepGame {
+	s := newIntroGame(l)
+	for i := 0; i < 100 && s.state.State() == Intro; i++ {
+		s.frame(nil)
+	}
+	return s
+}
+
+// newIntroGame is a stepGame at the start of the level intro.
+func newIntroGame(l *Level) *stepGame {
+	c := &StepClock{t: 1}
+	g = &Game{level: l, sched: NewScheduler(c), queueSize: DefaultQueueSize}
+	g.world = &World{eng: testEngine{}, texs: make([]sprite.SubTex, texEmpty+1)}
+	g.world.LoadScene()
+	return &stepGame{Game: g, clock: c}
+}
+
+// frame moves to the next frame, input is called before
+// the scene is arranged.
+func (s *stepGame) frame(input func()) {
+	s.clock.Step()
+	now, _ := s.sched.Frame()
+	s.Tick(now)
+	if input != nil {
+		input()
+	}
+	s.world.eng.Render(s.world.scene, now, size.Event{})
+}
+
+func TestSchedulerSameFrame(t *testing.T) {
+	c := &StepClock{}
+	s := NewScheduler(c)
+
+	_, ok := s.Frame()
+	assert.True(t, ok)
+	_, ok = s.Frame()
+	assert.False(t, ok)
+}
+
+func TestRotationTicks(t *testing.T) {
+	game := newStepGame(ParseLevel(codeLevel))
+
+	game.frame(func() { game.Press(0, true) })
+	for i := 1; i < rotateDuration; i++ {
+		game.frame(nil)
+		assert.NotNil(t, game.level.rotating, "tick %d", i)
+	}
+	game.frame(nil)
+
+	assert.Nil(t, game.level.rotating)
+	assert.Equal(t, 1, game.level.moves)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+)
+
+const (
+	MaxStars = 3
+	// MaxPoints is the score of a level solved
+	// with the optimal move count.
+	MaxPoints = 1000
+)
+
+// Score represents the result of a solved level.
+type Score struct {
+	Moves  int
+	Stars  int
+	Points int
+}
+
+// Better returns true if the score beats the other one.
+func (s Score) Better(other Score) bool {
+	return s.Points > other.Points
+}
+
+// optimum returns the move count of the level solution found
+// by the resolver. Without solution, or if the solution exceeds
+// the max moves, half of the max moves is considered as the
+// optimum.
+func (l *Level) optimum() int {
+	// Counter clockwise moves are followed by a quote
+	o := len(l.solution) - strings.Count(l.solution, "'")
+	if o == 0 || o > l.maxMoves {
+		o = l.maxMoves / 2
+	}
+	return o
+}
+
+// Score returns the result of the level according to the moves
+// used, from the optimum to the max moves. 3 stars are awarded up
+// to the optimum, 2 stars up to the middle to the max moves.
+func (l *Level) Score() Score {
+	s := Score{Moves: l.moves}
+	if !l.Win() {
+		return s
+	}
+	o := l.optimum()
+	extra := l.moves - o
+	if extra < 0 {
+		extra = 0
+	}
+	s.Points = MaxPoints - MaxPoints*extra/(l.maxMoves-o+1)
+	switch {
+	case extra == 0:
+		s.Stars = MaxStars
+	case extra <= (l.maxMoves-o)/2:
+		s.Stars = 2
+	default:
+		s.Stars = 1
+	}
+	return s
+}
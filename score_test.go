@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const scoreLevel = `01
+23
+
+0,0
+
+20
+31
+
+9
+
+7`
+
+func TestScoreOptimal(t *testing.T) {
+	l := ParseLevel(scoreLevel)
+
+	l.RotateSwitch(l.switches[0], true)
+	s := l.Score()
+
+	assert.True(t, l.Win())
+	assert.Equal(t, Score{Moves: 1, Stars: 3, Points: MaxPoints}, s)
+}
+
+func TestScoreStars(t *testing.T) {
+	l := ParseLevel(scoreLevel)
+
+	// A full turn before the solution
+	for i := 0; i < 5; i++ {
+		l.RotateSwitch(l.switches[0], true)
+	}
+	assert.Equal(t, 2, l.Score().Stars)
+	for i := 0; i < 4; i++ {
+		l.RotateSwitch(l.switches[0], true)
+	}
+	s := l.Score()
+
+	assert.Equal(t, 1, s.Stars)
+	assert.Equal(t, 9, s.Moves)
+	assert.True(t, Score{Points: MaxPoints}.Better(s))
+}
+
+func TestScoreNotWon(t *testing.T) {
+	l := ParseLevel(scoreLevel)
+
+	assert.Equal(t, 0, l.Score().Stars)
+}
+
+func TestOptimumWithoutSolution(t *testing.T) {
+	l := ParseLevel(scoreLevel)
+	l.solution = ""
+
+	assert.Equal(t, 4, l.optimum())
+}
+
+func TestOptimumSolutionTooLong(t *testing.T) {
+	l := ParseLevel(scoreLevel)
+	l.solution = "0000000000"
+
+	assert.Equal(t, 4, l.optimum())
+}
+
+func TestLevelSolutions(t *testing.T) {
+	for level := 1; hasLevel(level); level++ {
+		l := LoadLevel(level)
+		if l.solution == "" {
+			continue
+		}
+		assert.True(t, l.optimum() <= l.maxMoves, "level %d", level)
+		assert.NoError(t, playMoves(l, l.solution), "level %d", level)
+		assert.True(t, l.Win(), "level %d", level)
+	}
+}
+
+// playMoves turns the switches named by the moves, the ones
+// followed by a quote counter clockwise.
+func playMoves(l *Level, moves string) error {
+	m := []rune(moves)
+	for i := 0; i < len(m); i++ {
+		clockwise := i+1 >= len(m) || m[i+1] != '\''
+		s := l.switchNamed(string(m[i]))
+		if s < 0 {
+			return fmt.Errorf("unknown switch %c", m[i])
+		}
+		for _, r := range l.move(s, clockwise) {
+			l.rotateBlocks(l.switches[r.sw], r.clockwise)
+		}
+		if !clockwise {
+			i++
+		}
+	}
+	return nil
+}
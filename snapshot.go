@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Snapshot is the state of the level in progress, saved when
+// the app is stopped so the player can resume it.
+type Snapshot struct {
+	// Level is the level number, 0 for a custom level
+	Level int `json:"level"`
+	// Code is the level code of a custom level
+	Code string `json:"code,omitempty"`
+	// Board holds the block colors, line by line
+	Board []string `json:"board"`
+	Moves int      `json:"moves"`
+	// Rotated is the undo stack
+	Rotated [][]savedRotation `json:"rotated"`
+}
+
+type savedRotation struct {
+	Sw        int  `json:"sw"`
+	Clockwise bool `json:"cw"`
+}
+
+// Snapshot returns the state of the current level. The move
+// in progress is considered as done.
+func (g *Game) Snapshot() *Snapshot {
+	s := &Snapshot{Level: g.currentLevel}
+	if g.currentLevel == 0 {
+		s.Code = g.code
+	}
+	l := g.level.Copy()
+	l.moves = g.level.moves
+	l.rotating, l.undoing = g.level.rotating, g.level.undoing
+	l.applyRotating()
+	for i := range l.blocks {
+		var line []rune
+		for j := range l.blocks[i] {
+			line = append(line, rune(l.blocks[i][j].Color))
+		}
+		s.Board = append(s.Board, string(line))
+	}
+	s.Moves = l.moves
+	for _, m := range g.level.rotated {
+		var rs []savedRotation
+		for _, r := range m {
+			rs = append(rs, savedRotation{Sw: r.sw, Clockwise: r.clockwise})
+		}
+		s.Rotated = append(s.Rotated, rs)
+	}
+	return s
+}
+
+// Restore replays the moves of the snapshot on its level.
+func (s *Snapshot) Restore() (*Level, error) {
+	var l *Level
+	if s.Level == 0 {
+		var err error
+		if l, err = DecodeLevel(s.Code); err != nil {
+			return nil, err
+		}
+	} else {
+		if !hasLevel(s.Level) {
+			return nil, fmt.Errorf("unknown level %d", s.Level)
+		}
+		l = LoadLevel(s.Level)
+	}
+	for _, rs := range s.Rotated {
+		var m Move
+		for _, r := range rs {
+			if r.Sw < 0 || r.Sw >= len(l.switches) {
+				return nil, fmt.Errorf("unknown switch %d", r.Sw)
+			}
+			m = append(m, Rotation{sw: r.Sw, clockwise: r.Clockwise})
+		}
+		if len(m) == 0 {
+			return nil, errors.New("empty move")
+		}
+		if sw := l.switches[m[0].sw]; sw.uses > 0 {
+			sw.remaining--
+		}
+		for _, r := range m {
+			l.rotateBlocks(l.switches[r.sw], r.clockwise)
+		}
+		l.rotated = append(l.rotated, m)
+		l.moves++
+	}
+	// Ensure the level file didn't change since the snapshot
+	if l.moves != s.Moves || len(s.Board) != len(l.blocks) {
+		return nil, fmt.Errorf("snapshot doesn't match the level %d", s.Level)
+	}
+	for i := range l.blocks {
+		for j := range l.blocks[i] {
+			if j >= len(s.Board[i]) || l.blocks[i][j].Color != Color(s.Board[i][j]) {
+				return nil, fmt.Errorf("snapshot doesn't match the level %d", s.Level)
+			}
+		}
+	}
+	return l, nil
+}
+
+// SaveSnapshot saves the current level state. A level
+// won has nothing to resume, its score is recorded instead.
+func (g *Game) SaveSnapshot() {
+	if g.level.Win() {
+		g.recordScore()
+		g.store.Progress().Snapshot = nil
+	} else {
+		g.store.Progress().Snapshot = g.Snapshot()
+	}
+	if err := g.store.Save(); err != nil {
+		log.Println("Can't save the snapshot", err)
+	}
+}
+
+// restoreSnapshot resumes the level saved when the app
+// was stopped, if any.
+func (g *Game) restoreSnapshot() {
+	s := g.store.Progress().Snapshot
+	if s == nil {
+		return
+	}
+	l, err := s.Restore()
+	if err != nil {
+		log.Println("Can't restore the snapshot", err)
+		return
+	}
+	log.Printf("Level %d resumed at move %d", s.Level, s.Moves)
+	g.currentLevel = s.Level
+	g.code = s.Code
+	g.level = l
+}
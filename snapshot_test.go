@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	code, _ := EncodeLevel(l)
+	game := &Game{level: l, code: code}
+	game.level.triggerSwitch(2, true)
+	game.level.applyRotating()
+	// The last move is still rotating
+	game.level.triggerSwitch(0, true)
+	expected := game.level.Copy()
+	expected.RotateSwitch(expected.switches[0], true)
+
+	s := game.Snapshot()
+	data, err := json.Marshal(s)
+	assert.Nil(t, err)
+	var saved Snapshot
+	assert.Nil(t, json.Unmarshal(data, &saved))
+	restored, err := saved.Restore()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, restored.moves)
+	assert.Equal(t, Move{{sw: 2, clockwise: true}}, restored.rotated[0])
+	assert.Equal(t, 2, len(restored.rotated))
+	assert.Equal(t, expected.blockSignature(), restored.blockSignature())
+}
+
+func TestSnapshotCustomLevel(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	code, _ := EncodeLevel(l)
+	store, _ := NewStore(&memStorage{})
+	game := &Game{level: l, code: code, store: store}
+	game.level.triggerSwitch(2, true)
+	game.level.applyRotating()
+	game.SaveSnapshot()
+
+	resumed := &Game{store: store}
+	resumed.restoreSnapshot()
+	resumed.SaveSnapshot()
+	restored, err := store.Progress().Snapshot.Restore()
+
+	assert.Equal(t, code, resumed.code)
+	assert.Equal(t, 0, resumed.currentLevel)
+	assert.Nil(t, err)
+	assert.Equal(t, game.level.blockSignature(), restored.blockSignature())
+}
+
+func TestSnapshotWonLevel(t *testing.T) {
+	store, _ := NewStore(&memStorage{})
+	game := &Game{level: ParseLevel(scoreLevel), currentLevel: 1, store: store}
+	game.SaveSnapshot()
+	game.level.RotateSwitch(game.level.switches[0], true)
+
+	game.SaveSnapshot()
+
+	assert.Nil(t, store.Progress().Snapshot)
+	assert.Equal(t, 2, store.Progress().Unlocked)
+}
+
+func TestSnapshotMismatch(t *testing.T) {
+	l := ParseLevel(codeLevel)
+	code, _ := EncodeLevel(l)
+	game := &Game{level: l, code: code}
+	game.level.triggerSwitch(2, true)
+	game.level.applyRotating()
+
+	s := game.Snapshot()
+	s.Board[0] = "0000"
+	_, err := s.Restore()
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"log"
+
+	"golang.org/x/mobile/exp/sprite/clock"
+)
+
+// State is the step of the game which decides
+// what the player input does.
+type State int
+
+const (
+	// Intro is the level appearance, the input is ignored
+	Intro State = iota
+	// Playing waits for the player moves
+	Playing
+	// Rotating animates a move, the input is queued
+	Rotating
+	// Won and Lost wait for the player to go on
+	Won
+	Lost
+	// Paused ignores the input until the game resumes
+	Paused
+	// Menu shows the level select, until a level is
+	// selected or the game resumes
+	Menu
+)
+
+var stateNames = []string{"Intro", "Playing", "Rotating", "Won", "Lost", "Paused", "Menu"}
+
+func (s State) String() string {
+	return stateNames[s]
+}
+
+// StateEvent triggers the transitions between the states.
+type StateEvent int
+
+const (
+	// OnLoad is fired when a level is loaded
+	OnLoad StateEvent = iota
+	// OnIntroDone is fired when the level appearance is over
+	OnIntroDone
+	// OnMove is fired when a move starts
+	OnMove
+	// OnMoveDone is fired when the move is applied
+	OnMoveDone
+	OnWin
+	OnLose
+	OnPause
+	OnResume
+	// OnMenu is fired when the level select opens
+	OnMenu
+)
+
+var stateEventNames = []string{"Load", "IntroDone", "Move", "MoveDone", "Win", "Lose", "Pause", "Resume", "Menu"}
+
+func (e StateEvent) String() string {
+	return stateEventNames[e]
+}
+
+// transitions lists the states reached by the events, the
+// events missing from a state are ignored. OnLoad, OnPause,
+// OnResume and OnMenu are valid from any state. The events
+// fired during a pause apply to the state left, which the
+// game resumes.
+var transitions = map[State]map[StateEvent]State{
+	Intro: {
+		OnIntroDone: Playing,
+	},
+	Playing: {
+		OnMove: Rotating,
+		// Won without a move, like a level resumed on its win
+		OnWin:  Won,
+		OnLose: Lost,
+	},
+	Rotating: {
+		OnMoveDone: Playing,
+		OnWin:      Won,
+		OnLose:     Lost,
+	},
+}
+
+const (
+	// wonDelay and lostDelay wait for the end of the win and loose
+	// text animations, plus one second, before the player can go on.
+	wonDelay  = 20 + FPS
+	lostDelay = 40 + FPS
+)
+
+// StateMachine holds the current state of the game.
+type StateMachine struct {
+	state State
+	// resume is the state left when the game was paused
+	resume State
+	// since is the time the current state was entered
+	since clock.Time
+}
+
+// State returns the current state.
+func (m *StateMachine) State() State {
+	return m.state
+}
+
+// Fire applies the transition of the event, it returns false
+// if the event is ignored in the current state.
+func (m *StateMachine) Fire(e StateEvent, now clock.Time) bool {
+	var next State
+	switch {
+	case m.state == Paused && e != OnPause && e != OnMenu && e != OnResume:
+		// The event applies to the state left,
+		// the game stays paused.
+		next = Intro
+		if e != OnLoad {
+			var ok bool
+			if next, ok = transitions[m.resume][e]; !ok {
+				return false
+			}
+		}
+		log.Printf("State %v -> %v on %v, paused", m.resume, next, e)
+		m.resume = next
+		m.since = now
+		return true
+	case e == OnLoad:
+		next = Intro
+	case e == OnPause, e == OnMenu:
+		if m.state == Paused || m.state == Menu {
+			return false
+		}
+		m.resume = m.state
+		next = Paused
+		if e == OnMenu {
+			next = Menu
+		}
+	case e == OnResume:
+		if m.state != Paused && m.state != Menu {
+			return false
+		}
+		next = m.resume
+	default:
+		var ok bool
+		if next, ok = transitions[m.state][e]; !ok {
+			return false
+		}
+	}
+	log.Printf("State %v -> %v on %v", m.state, next, e)
+	if e != OnResume {
+		m.since = now
+	}
+	m.state = next
+	return true
+}
+
+// Ready returns true if the player can go on after a win or a loss.
+func (m *StateMachine) Ready(now clock.Time) bool {
+	switch m.state {
+	case Won:
+		return now-m.since >= wonDelay
+	case Lost:
+		return now-m.since >= lostDelay
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateTransitions(t *testing.T) {
+	for _, tt := range []struct {
+		from     State
+		event    StateEvent
+		expected State
+		ok       bool
+	}{
+		{Intro, OnIntroDone, Playing, true},
+		{Intro, OnMove, Intro, false},
+		{Intro, OnLoad, Intro, true},
+		{Playing, OnMove, Rotating, true},
+		{Playing, OnLose, Lost, true},
+		{Playing, OnMoveDone, Playing, false},
+		{Playing, OnWin, Won, true},
+		{Playing, OnLoad, Intro, true},
+		{Rotating, OnMoveDone, Playing, true},
+		{Rotating, OnWin, Won, true},
+		{Rotating, OnLose, Lost, true},
+		{Rotating, OnMove, Rotating, false},
+		{Won, OnLoad, Intro, true},
+		{Won, OnMove, Won, false},
+		{Lost, OnLoad, Intro, true},
+		{Lost, OnIntroDone, Lost, false},
+		{Playing, OnPause, Paused, true},
+		{Won, OnPause, Paused, true},
+		{Paused, OnPause, Paused, false},
+		{Paused, OnMove, Paused, false},
+		{Paused, OnLoad, Paused, true},
+		{Paused, OnIntroDone, Paused, true},
+		{Playing, OnResume, Playing, false},
+		{Playing, OnMenu, Menu, true},
+		{Paused, OnMenu, Paused, false},
+		{Menu, OnPause, Menu, false},
+		{Menu, OnMove, Menu, false},
+		{Menu, OnLoad, Intro, true},
+	} {
+		m := StateMachine{state: tt.from}
+
+		ok := m.Fire(tt.event, 1)
+
+		assert.Equal(t, tt.ok, ok, "%v on %v", tt.from, tt.event)
+		assert.Equal(t, tt.expected, m.State(), "%v on %v", tt.from, tt.event)
+	}
+}
+
+func TestStateResume(t *testing.T) {
+	m := StateMachine{state: Rotating}
+
+	m.Fire(OnPause, 1)
+	assert.True(t, m.Fire(OnResume, 2))
+	assert.Equal(t, Rotating, m.State())
+
+	m.Fire(OnPause, 3)
+	m.Fire(OnLoad, 4)
+	m.Fire(OnResume, 5)
+	// The level loaded during the pause starts
+	assert.Equal(t, Intro, m.State())
+}
+
+func TestPauseDuringIntro(t *testing.T) {
+	m := StateMachine{state: Intro}
+
+	m.Fire(OnPause, 1)
+	assert.True(t, m.Fire(OnIntroDone, 2))
+	assert.Equal(t, Paused, m.State())
+	m.Fire(OnResume, 3)
+
+	assert.Equal(t, Playing, m.State())
+}
+
+func TestGamePausedDuringIntro(t *testing.T) {
+	game := newIntroGame(ParseLevel(codeLevel))
+
+	game.Pause()
+	for i := 0; i < 200; i++ {
+		game.frame(nil)
+	}
+	game.Resume()
+	for i := 0; i < 10; i++ {
+		game.frame(nil)
+	}
+
+	assert.Equal(t, Playing, game.state.State())
+}
+
+func TestGameResumedOnWin(t *testing.T) {
+	game := newStepGame(ParseLevel(scoreLevel))
+	game.level.rotateBlocks(game.level.switches[0], true)
+
+	game.frame(nil)
+
+	assert.Equal(t, Won, game.state.State())
+}
+
+func TestStateReady(t *testing.T) {
+	m := StateMachine{state: Rotating}
+	m.Fire(OnWin, 10)
+
+	assert.False(t, m.Ready(10+wonDelay-1))
+	assert.True(t, m.Ready(10+wonDelay))
+}
+
+func TestGameIntro(t *testing.T) {
+	game := newStepGame(ParseLevel(codeLevel))
+
+	assert.Equal(t, Playing, game.state.State())
+}
+
+func TestGameMove(t *testing.T) {
+	game := newStepGame(ParseLevel(codeLevel))
+
+	game.frame(func() { game.Press(0, true) })
+	assert.Equal(t, Rotating, game.state.State())
+	for i := 0; i < rotateDuration; i++ {
+		game.frame(nil)
+	}
+	// The move done is noticed at the next frame
+	game.frame(nil)
+
+	assert.Equal(t, Playing, game.state.State())
+}
+
+func TestGameQueuedInput(t *testing.T) {
+	game := newStepGame(ParseLevel(codeLevel))
+
+	game.frame(func() {
+		game.Press(0, true)
+		game.Press(1, true)
+	})
+	assert.Equal(t, 1, len(game.queue))
+	for i := 0; i < 2*rotateDuration+4; i++ {
+		game.frame(nil)
+	}
+
+	assert.Equal(t, Playing, game.state.State())
+	assert.Equal(t, 2, game.level.moves)
+	assert.Equal(t, 0, len(game.queue))
+}
+
+func TestGameWon(t *testing.T) {
+	game := newStepGame(ParseLevel(scoreLevel))
+
+	game.frame(func() { game.Press(0, true) })
+	for i := 0; i <= rotateDuration; i++ {
+		game.frame(nil)
+	}
+	assert.Equal(t, Won, game.state.State())
+	// Ignored until the win animation is over
+	game.Continue()
+	assert.Equal(t, Won, game.state.State())
+	for i := 0; i < wonDelay; i++ {
+		game.frame(nil)
+	}
+
+	assert.True(t, game.state.Ready(game.now))
+}
+
+func TestGameLost(t *testing.T) {
+	l := ParseLevel(strings.Replace(scoreLevel, "\n9\n", "\n1\n", 1))
+	game := newStepGame(l)
+
+	game.frame(func() { game.Press(0, false) })
+	for i := 0; i <= rotateDuration; i++ {
+		game.frame(nil)
+	}
+
+	assert.Equal(t, Lost, game.state.State())
+}
+
+func TestGamePaused(t *testing.T) {
+	game := newStepGame(ParseLevel(codeLevel))
+
+	game.Pause()
+	game.frame(func() { game.Press(0, true) })
+	assert.Equal(t, Paused, game.state.State())
+	assert.Nil(t, game.level.rotating)
+	game.Resume()
+
+	assert.Equal(t, Playing, game.state.State())
+}
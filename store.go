@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// SaveSlots is the number of players which can
+// save their progress.
+const SaveSlots = 3
+
+// Storage reads and writes the saved data.
+type Storage interface {
+	// Read returns nil if nothing is saved yet
+	Read() ([]byte, error)
+	Write(data []byte) error
+}
+
+// Progress is the saved progress of a player.
+type Progress struct {
+	// Unlocked is the highest level reached
+	Unlocked int `json:"unlocked"`
+	// Best holds the best score of each level
+	Best map[int]Score `json:"best"`
+	// Snapshot is the level in progress when the app stopped
+	Snapshot *Snapshot `json:"snapshot,omitempty"`
+	// Lives is the remaining lives of the LoseLife policy
+	Lives int `json:"lives"`
+}
+
+// Settings are the player preferences.
+type Settings struct {
+	// Slot is the save slot in use
+	Slot       int  `json:"slot"`
+	TimeAttack bool `json:"timeAttack"`
+	// FastQueue speeds up the moves queued during a rotation
+	FastQueue bool `json:"fastQueue"`
+	// LosePolicy is empty for the default policy
+	LosePolicy LosePolicy `json:"losePolicy,omitempty"`
+}
+
+// Store holds the saved data.
+type Store struct {
+	storage  Storage
+	Settings Settings   `json:"settings"`
+	Slots    []Progress `json:"slots"`
+}
+
+// NewStore reads the saved data from the storage.
+func NewStore(storage Storage) (*Store, error) {
+	s := &Store{storage: storage}
+	data, err := storage.Read()
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, s); err != nil {
+			return nil, err
+		}
+	}
+	for len(s.Slots) < SaveSlots {
+		s.Slots = append(s.Slots, Progress{})
+	}
+	if s.Settings.Slot < 0 || s.Settings.Slot >= len(s.Slots) {
+		log.Printf("Unknown save slot %d, using the first one", s.Settings.Slot)
+		s.Settings.Slot = 0
+	}
+	for i := range s.Slots {
+		if s.Slots[i].Unlocked == 0 {
+			s.Slots[i].Unlocked = 1
+		}
+		if s.Slots[i].Lives == 0 {
+			s.Slots[i].Lives = MaxLives
+		}
+		if s.Slots[i].Best == nil {
+			s.Slots[i].Best = make(map[int]Score)
+		}
+	}
+	return s, nil
+}
+
+// Progress returns the progress of the slot in use.
+func (s *Store) Progress() *Progress {
+	return &s.Slots[s.Settings.Slot]
+}
+
+// SelectSlot changes the slot in use.
+func (s *Store) SelectSlot(slot int) error {
+	if slot < 0 || slot >= len(s.Slots) {
+		return fmt.Errorf("unknown save slot %d", slot)
+	}
+	s.Settings.Slot = slot
+	return s.Save()
+}
+
+// Record keeps the score if it beats the previous one, and
+// unlocks the next level.
+func (s *Store) Record(level int, score Score) error {
+	p := s.Progress()
+	if best, ok := p.Best[level]; !ok || score.Better(best) {
+		log.Printf("Best score for level %d: %+v", level, score)
+		p.Best[level] = score
+	}
+	if level >= p.Unlocked {
+		p.Unlocked = level + 1
+	}
+	return s.Save()
+}
+
+func (s *Store) Save() error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return s.storage.Write(data)
+}
+
+// fileStorage saves the data in a JSON file.
+type fileStorage struct {
+	path string
+}
+
+// NewFileStorage returns the storage of the app data directory.
+func NewFileStorage() (Storage, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "mozaik")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileStorage{path: filepath.Join(dir, "save.json")}, nil
+}
+
+func (f *fileStorage) Read() ([]byte, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (f *fileStorage) Write(data []byte) error {
+	// Write a temporary file first to never lose the saved data
+	tmp := f.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// memStorage keeps the data in memory, when no file can
+// be used and for the tests.
+type memStorage struct {
+	data []byte
+}
+
+func (m *memStorage) Read() ([]byte, error) {
+	return m.data, nil
+}
+
+func (m *memStorage) Write(data []byte) error {
+	m.data = data
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreNew(t *testing.T) {
+	s, err := NewStore(&memStorage{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, SaveSlots, len(s.Slots))
+	assert.Equal(t, 1, s.Progress().Unlocked)
+}
+
+func TestStoreRecord(t *testing.T) {
+	storage := &memStorage{}
+	s, _ := NewStore(storage)
+
+	assert.Nil(t, s.Record(1, Score{Moves: 8, Stars: 2, Points: 500}))
+	assert.Nil(t, s.Record(1, Score{Moves: 10, Stars: 1, Points: 200}))
+	s, err := NewStore(storage)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, s.Progress().Unlocked)
+	assert.Equal(t, Score{Moves: 8, Stars: 2, Points: 500}, s.Progress().Best[1])
+}
+
+func TestStoreSlots(t *testing.T) {
+	storage := &memStorage{}
+	s, _ := NewStore(storage)
+	s.Record(3, Score{Stars: 3})
+
+	assert.Nil(t, s.SelectSlot(1))
+	assert.Equal(t, 1, s.Progress().Unlocked)
+	assert.NotNil(t, s.SelectSlot(SaveSlots))
+	s, _ = NewStore(storage)
+	assert.Equal(t, 1, s.Settings.Slot)
+	assert.Equal(t, 4, s.Slots[0].Unlocked)
+}
+
+func TestStoreBadSlot(t *testing.T) {
+	for _, data := range []string{`{"settings":{"slot":5}}`, `{"settings":{"slot":-1}}`} {
+		s, err := NewStore(&memStorage{data: []byte(data)})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, s.Settings.Slot, data)
+		assert.Equal(t, 1, s.Progress().Unlocked)
+	}
+}
+
+func TestStoreFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mozaik")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	storage := &fileStorage{path: filepath.Join(dir, "save.json")}
+	s, err := NewStore(storage)
+	assert.Nil(t, err)
+
+	s.Settings.TimeAttack = true
+	assert.Nil(t, s.Save())
+	s, err = NewStore(storage)
+
+	assert.Nil(t, err)
+	assert.True(t, s.Settings.TimeAttack)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"golang.org/x/mobile/exp/sprite/clock"
+)
+
+const (
+	// TimeAttackSeconds is the initial time of the time-attack mode
+	TimeAttackSeconds = 60
+	// TimeBonusSeconds is the time added when a level is solved,
+	// plus one second per remaining move.
+	TimeBonusSeconds = 10
+	// MaxTimeSeconds is the limit of the countdown, which is
+	// displayed with 2 digits.
+	MaxTimeSeconds = 99
+)
+
+// Timer is the countdown of the time-attack mode. It is driven
+// by the clock of the draw loop.
+type Timer struct {
+	remaining clock.Time
+	// last is the time of the last tick, -1 after a pause
+	last   clock.Time
+	paused bool
+}
+
+func NewTimer(seconds int) *Timer {
+	return &Timer{remaining: clock.Time(seconds * FPS), last: -1}
+}
+
+// Tick consumes the time elapsed since the previous tick.
+func (t *Timer) Tick(now clock.Time) {
+	if t.paused {
+		return
+	}
+	if t.last >= 0 && now > t.last {
+		t.remaining -= now - t.last
+		if t.remaining < 0 {
+			t.remaining = 0
+		}
+	}
+	t.last = now
+}
+
+// Pause stops the countdown, the time elapsed until
+// the next Resume is not consumed.
+func (t *Timer) Pause() {
+	t.paused = true
+	t.last = -1
+}
+
+func (t *Timer) Resume() {
+	t.paused = false
+}
+
+// Add gives extra seconds, up to MaxTimeSeconds.
+func (t *Timer) Add(seconds int) {
+	t.remaining += clock.Time(seconds * FPS)
+	if max := clock.Time(MaxTimeSeconds * FPS); t.remaining > max {
+		t.remaining = max
+	}
+}
+
+// Seconds returns the remaining seconds, rounded up.
+func (t *Timer) Seconds() int {
+	return int((t.remaining + FPS - 1) / FPS)
+}
+
+func (t *Timer) Expired() bool {
+	return t.remaining == 0
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimerCountdown(t *testing.T) {
+	timer := NewTimer(2)
+
+	timer.Tick(100)
+	assert.Equal(t, 2, timer.Seconds())
+	timer.Tick(100 + FPS/2)
+	assert.Equal(t, 2, timer.Seconds())
+	timer.Tick(100 + FPS)
+	assert.Equal(t, 1, timer.Seconds())
+	timer.Tick(100 + FPS*3)
+	assert.Equal(t, 0, timer.Seconds())
+	assert.True(t, timer.Expired())
+}
+
+func TestTimerPause(t *testing.T) {
+	timer := NewTimer(2)
+	timer.Tick(0)
+
+	timer.Pause()
+	timer.Tick(FPS * 10)
+	timer.Resume()
+	// The time elapsed during the pause is not consumed
+	timer.Tick(FPS * 20)
+	timer.Tick(FPS*20 + FPS)
+
+	assert.Equal(t, 1, timer.Seconds())
+}
+
+func TestTimerBonus(t *testing.T) {
+	timer := NewTimer(10)
+
+	timer.Add(TimeBonusSeconds)
+	assert.Equal(t, 20, timer.Seconds())
+	timer.Add(200)
+	assert.Equal(t, MaxTimeSeconds, timer.Seconds())
+}
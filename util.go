@@ -1,6 +1,10 @@
 package main
 
-import "golang.org/x/mobile/exp/f32"
+import (
+	"math"
+
+	"golang.org/x/mobile/exp/f32"
+)
 
 func identity() *f32.Mat4 {
 	id := &f32.Mat4{}
@@ -50,3 +54,14 @@ func ortho(w, h float32) *f32.Mat4 {
 	//  0.0, 0.0, -1.0, 0.0,
 	//  0.0, 0.0, 0.0, 1.0);  )
 }
+
+// pivot returns the center of the rotation of angle which
+// moves the point a to the point b.
+func pivot(ax, ay, bx, by, angle float32) (float32, float32) {
+	c, s := float32(math.Cos(float64(angle))), float32(math.Sin(float64(angle)))
+	// Solve p = b - rot(a - p)
+	rx := bx - (c*ax - s*ay)
+	ry := by - (s*ax + c*ay)
+	det := 2 - 2*c
+	return ((1-c)*rx - s*ry) / det, (s*rx + (1-c)*ry) / det
+}
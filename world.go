@@ -16,10 +16,33 @@ import (
 type World struct {
 	background  *Background
 	moveCounter *Number
-	levelLabel  *LevelLabel
-	scene       *sprite.Node
-	eng         sprite.Engine
-	texs        []sprite.SubTex
+	// timeCounter displays the remaining seconds
+	// in the time-attack mode.
+	timeCounter   *Number
+	undoButton    *Button
+	redoButton    *Button
+	restartButton *Button
+	menuButton    *Button
+	levelLabel    *LevelLabel
+	scene         *sprite.Node
+	eng           sprite.Engine
+	texs          []sprite.SubTex
+	// menu is the level select scene, thumbs
+	// are the cells of its levels.
+	menu       *sprite.Node
+	thumbs     []*Object
+	codeButton *Button
+	timeButton *Button
+	// prompt is the level code prompt scene, keys
+	// are the cells of its characters.
+	prompt      *sprite.Node
+	keys        []*Object
+	backButton  *Button
+	eraseButton *Button
+	okButton    *Button
+	// glyphs are the textures of the code
+	// characters, in the code alphabet order.
+	glyphs []sprite.SubTex
 }
 
 func compute(val float32, factor float32) float32 {
@@ -37,6 +60,7 @@ func NewWorld(glctx gl.Context) *World {
 	w.eng = eng
 	w.loadTextures()
 	w.LoadScene()
+	w.LoadMenu()
 	return w
 }
 
@@ -52,17 +76,90 @@ func (w *World) LoadScene() {
 		for j := range g.level.blocks[i] {
 			b := g.level.blocks[i][j]
 			n := w.newNode()
-			b.Layout(i, j, blockSize, blockPadding, xMin, yMin)
+			if g.level.hex {
+				b.LayoutHex(i, j, blockSize, blockPadding, xMin, yMin)
+			} else {
+				b.Layout(i, j, blockSize, blockPadding, xMin, yMin)
+			}
 			n.Arranger = &b.Object
 			w.scene.AppendChild(n)
 		}
 	}
+	// Add the lock overlays over the locked blocks
+	for i := range g.level.blocks {
+		for j := range g.level.blocks[i] {
+			b := g.level.blocks[i][j]
+			if !b.locked {
+				continue
+			}
+			n := w.newNode()
+			lockSize := blockSize / 3
+			n.Arranger = &Object{
+				X:      b.X + b.Width - lockSize,
+				Y:      b.Y,
+				Width:  lockSize,
+				Height: lockSize,
+				Sprite: w.texs[texLock],
+				Action: ActionFunc(lockIdle),
+			}
+			w.scene.AppendChild(n)
+		}
+	}
+	// Add the highlights of the blocks turned
+	// by the switch under the mouse
+	for i := range g.level.blocks {
+		for j := range g.level.blocks[i] {
+			b := g.level.blocks[i][j]
+			n := w.newNode()
+			n.Arranger = &Object{
+				X:      b.X,
+				Y:      b.Y,
+				Width:  b.Width,
+				Height: b.Height,
+				Data:   b,
+				Action: ActionFunc(blockHighlightIdle),
+			}
+			w.scene.AppendChild(n)
+		}
+	}
 	// Create the switches
 	for _, sw := range g.level.switches {
 		n := w.newNode()
 		sw.Layout(switchSize)
 		n.Arranger = &sw.Object
 		w.scene.AppendChild(n)
+		w.addGhosts(sw)
+		if sw.uses > 0 {
+			// Display the remaining uses at the top right of the switch
+			n := w.newNode()
+			h := switchSize * .6
+			cw := h * TexCharWidth / TexCharHeight
+			n.Arranger = &Object{
+				X:      sw.X + switchSize - cw/2,
+				Y:      sw.Y - h/2,
+				Width:  cw,
+				Height: h,
+				Data:   sw,
+				Action: ActionFunc(switchUsesIdle),
+			}
+			w.scene.AppendChild(n)
+		}
+		if sw.name != "x" {
+			// Display the numpad key over the switch,
+			// once a keyboard is used
+			n := w.newNode()
+			h := switchSize * .5
+			cw := h * TexCharWidth / TexCharHeight
+			n.Arranger = &Object{
+				X:      sw.X + (switchSize-cw)/2,
+				Y:      sw.Y + (switchSize-h)/2,
+				Width:  cw,
+				Height: h,
+				Data:   sw,
+				Action: ActionFunc(switchKeyIdle),
+			}
+			w.scene.AppendChild(n)
+		}
 	}
 
 	// The bottom dashboard
@@ -89,23 +186,7 @@ func (w *World) LoadScene() {
 		{1, 0, windowWidth - signSize - padding},
 		{0, 1, windowHeight - signSize - padding},
 	})
-	line, col := 0, 0
-	for i := range g.level.winSignature {
-		for j := range g.level.winSignature[i] {
-			c := g.level.winSignature[i][j]
-			if c != Empty {
-				n := w.newNode()
-				signature.AppendChild(n)
-				b := &Block{Color: c}
-				b.Action = ActionFunc(signatureBlockIdle)
-				b.Layout(line, col, signatureBlockSize, 0, 0, 0)
-				n.Arranger = &b.Object
-			}
-			col++
-		}
-		line++
-		col = 0
-	}
+	w.addSignature(signature, g.level, signatureBlockSize)
 
 	// The move counter
 	var counterX, counterY float32
@@ -117,6 +198,15 @@ func (w *World) LoadScene() {
 		counterY = padding + signSize/2 - charHeight/2
 	}
 	w.moveCounter = w.newNumber(w.scene, counterX, counterY)
+	w.timeCounter = nil
+	if g.timer != nil {
+		// The countdown is next to the move counter
+		if portrait {
+			w.timeCounter = w.newNumber(w.scene, padding, counterY)
+		} else {
+			w.timeCounter = w.newNumber(w.scene, counterX, counterY+charHeight+padding)
+		}
+	}
 
 	// Add the win text node
 	{
@@ -132,6 +222,76 @@ func (w *World) LoadScene() {
 		}
 	}
 
+	// The history buttons
+	var buttonX, buttonY float32
+	if portrait {
+		buttonX = padding
+		buttonY = windowHeight - padding - switchSize
+	} else {
+		buttonX = windowWidth - padding - switchSize*2 - blockPadding
+		buttonY = windowHeight/2 - switchSize/2
+	}
+	w.undoButton = w.newButton(w.scene, buttonX, buttonY, texUndo, func() bool {
+		return len(g.level.rotated) > 0
+	})
+	w.redoButton = w.newButton(w.scene, buttonX+switchSize+padding/2, buttonY, texRedo, func() bool {
+		return len(g.level.undone) > 0
+	})
+	// The restart button follows the history buttons, on the same
+	// line in portrait and below in landscape. The lives are above
+	// the buttons in portrait.
+	var restartX, restartY float32
+	if portrait {
+		restartX, restartY = buttonX+(switchSize+padding/2)*2, buttonY
+	} else {
+		restartX, restartY = buttonX, buttonY+switchSize+padding/2
+	}
+	w.restartButton = w.newButton(w.scene, restartX, restartY, texRestart, func() bool {
+		return len(g.level.rotated) > 0 && g.state.State() != Lost
+	})
+	// The menu button ends the line in portrait,
+	// and is at the top right in landscape.
+	menuX, menuY := restartX+switchSize+padding/2, restartY
+	if !portrait {
+		menuX, menuY = windowWidth-padding-switchSize, padding
+	}
+	w.menuButton = w.newButton(w.scene, menuX, menuY, texMenu, func() bool {
+		return true
+	})
+	if g.losePolicy == LoseLife {
+		heartSize := switchSize / 2
+		heartX, heartY := buttonX, buttonY-heartSize-padding/4
+		if !portrait {
+			heartX, heartY = buttonX, restartY+switchSize+padding/2
+		}
+		for i := 0; i < MaxLives; i++ {
+			n := w.newNode()
+			w.scene.AppendChild(n)
+			n.Arranger = &Object{
+				X:      heartX + float32(i)*heartSize,
+				Y:      heartY,
+				Width:  heartSize,
+				Height: heartSize,
+				Data:   i,
+				Action: ActionFunc(heartIdle),
+			}
+		}
+	}
+
+	// The score stars below the win text
+	for i := 0; i < MaxStars; i++ {
+		n := w.newNode()
+		w.scene.AppendChild(n)
+		n.Arranger = &Object{
+			X:      windowWidth/2 + (float32(i)-float32(MaxStars)/2)*switchSize,
+			Y:      windowHeight/2 + winTxtHeight/2 + padding,
+			Width:  switchSize,
+			Height: switchSize,
+			Data:   i,
+			Action: ActionFunc(starPop),
+		}
+	}
+
 	// The loose text node
 	{
 		n := w.newNode()
@@ -149,18 +309,114 @@ func (w *World) LoadScene() {
 	// The level text node
 	w.levelLabel = w.newLevelLabel()
 	w.levelLabel.SetNumber(w, g.currentLevel)
-	if g.level.moves == 0 {
+	if g.level.moves == 0 && g.currentLevel > 0 {
 		// Animate only if no movement
 		// This prevent the level label to pop on hot start.
+		// Custom levels have no number to display.
 		w.levelLabel.Action = wait{until: clock.Time(20), next: ActionFunc(levelLabelPop)}
 	}
 }
 
+// addSignature displays the win signature of the level,
+// or its pattern goal, with blocks of the given size.
+func (w *World) addSignature(signature *sprite.Node, l *Level, size float32) {
+	switch l.goal.(type) {
+	case distinctRowsGoal:
+		w.addGoalIcon(signature, texGoalRows, size*4)
+		return
+	case noAdjacentGoal:
+		w.addGoalIcon(signature, texGoalNoAdjacent, size*4)
+		return
+	}
+	for i := range l.winSignature {
+		for j := range l.winSignature[i] {
+			c := l.winSignature[i][j]
+			if c == Empty {
+				continue
+			}
+			n := w.newNode()
+			signature.AppendChild(n)
+			b := &Block{Color: c}
+			if l.hex {
+				b.LayoutHex(i, j, size, 0, 0, 0)
+			} else {
+				b.Layout(i, j, size, 0, 0, 0)
+			}
+			if c == Ignored {
+				// Any block fits, draw an outline
+				b.Sprite = w.texs[texOutline]
+			} else {
+				b.Sprite = w.texs[blockTex(c, l.hex)]
+			}
+			n.Arranger = &b.Object
+		}
+	}
+}
+
+// addGoalIcon displays the pattern goal in place
+// of the win signature.
+func (w *World) addGoalIcon(signature *sprite.Node, tex int, size float32) {
+	n := w.newNode()
+	signature.AppendChild(n)
+	n.Arranger = &Object{
+		Width:  size,
+		Height: size,
+		Sprite: w.texs[tex],
+	}
+}
+
+// addGhosts displays the parts of a switch wrapping around
+// the board edges on the opposite edges.
+func (w *World) addGhosts(sw *Switch) {
+	sw.ghosts = nil
+	bottom, right := g.level.wraps(sw)
+	dy := -float32(len(g.level.blocks)) * (blockSize + blockPadding*2)
+	dx := -float32(len(g.level.blocks[0])) * (blockSize + blockPadding*2)
+	var offsets [][2]float32
+	if bottom {
+		offsets = append(offsets, [2]float32{0, dy})
+	}
+	if right {
+		offsets = append(offsets, [2]float32{dx, 0})
+	}
+	if bottom && right {
+		offsets = append(offsets, [2]float32{dx, dy})
+	}
+	for _, off := range offsets {
+		n := w.newNode()
+		o := &Object{
+			X:      sw.X + off[0],
+			Y:      sw.Y + off[1],
+			Width:  switchSize,
+			Height: switchSize,
+			Data:   sw,
+			Action: ActionFunc(switchGhost),
+		}
+		n.Arranger = o
+		w.scene.AppendChild(n)
+		sw.ghosts = append(sw.ghosts, o)
+	}
+}
+
 func (w *World) Draw(glctx gl.Context, t clock.Time, sz size.Event) {
 	// Background
 	w.background.Draw()
+	if g.state.State() == Menu {
+		if g.prompt != nil {
+			// The code prompt
+			w.eng.Render(w.prompt, t, sz)
+			return
+		}
+		// The level select
+		w.eng.Render(w.menu, t, sz)
+		return
+	}
 	// the move counter
 	w.moveCounter.Set(w, g.level.RemainMoves())
+	if w.timeCounter != nil {
+		// the countdown
+		w.timeCounter.Set(w, g.timer.Seconds())
+	}
 	// The scene
 	w.eng.Render(w.scene, t, sz)
 }
@@ -171,6 +427,42 @@ func (w *World) newNode() *sprite.Node {
 	return n
 }
 
+// Button is a dashboard button, hidden when it
+// can't be used.
+type Button struct {
+	Object
+	tex     int
+	enabled func() bool
+}
+
+func (w *World) newButton(parent *sprite.Node, x, y float32, tex int, enabled func() bool) *Button {
+	n := w.newNode()
+	parent.AppendChild(n)
+	b := &Button{
+		Object: Object{
+			X: x, Y: y, Width: switchSize, Height: switchSize,
+			Action: ActionFunc(buttonIdle),
+		},
+		tex:     tex,
+		enabled: enabled,
+	}
+	b.Data = b
+	n.Arranger = &b.Object
+	return b
+}
+
+// usable returns true if the button is enabled, the
+// dashboard ones are hidden while the level is won.
+func (b *Button) usable() bool {
+	return b.enabled() && (g.state.State() == Menu || !g.level.Win())
+}
+
+// hit returns true if the usable button is
+// at the coordinates.
+func (b *Button) hit(x, y float32) bool {
+	return b != nil && b.usable() && touched(&b.Object, x, y)
+}
+
 type LevelLabel struct {
 	Object
 	number *Number
@@ -311,6 +603,46 @@ const (
 	tex9
 	texLooseTxt
 	texLeveltxt
+	texSwitchCCW
+	texSwitchBoth
+	texLock
+	texArrowRight
+	texArrowLeft
+	texArrowDown
+	texArrowUp
+	// The hex textures follow the block texture order
+	texHexRed
+	texHexYellow
+	texHexBlue
+	texHexGreen
+	texHexPink
+	texHexOrange
+	texHexLightBlue
+	texHexPurple
+	texHexBrown
+	texHexLightGreen
+	texHexCyan
+	texHexLightPink
+	texHexWhite
+	texHexLightPurple
+	texHexLightBrown
+	texHexOtherWhite
+	texGoalRows
+	texGoalNoAdjacent
+	texOutline
+	texHighlight
+	texStarFull
+	texStarEmpty
+	texUndo
+	texRedo
+	texRestart
+	texMenu
+	texHeart
+	texCode
+	texErase
+	texOk
+	texClock
+	texClockOff
 	texEmpty
 )
 
@@ -325,6 +657,23 @@ const (
 	TexGameoverHeight = 106
 	TexLevelWidth     = 325
 	TexLevelHeight    = 106
+	// TexIconsY is the top of the icon line
+	TexIconsY   = 616
+	TexIconSize = 50
+	// TexHexY is the top of the hex block line, the hex
+	// blocks are in the same order than the square ones.
+	TexHexY    = 672
+	TexHexSize = 64
+	// TexGoalsY is the top of the pattern goal icons,
+	// after the ignored cell outline.
+	TexGoalsY   = 736
+	TexGoalSize = 64
+	// TexGlyphX is the left of the code characters,
+	// on two lines after the pattern goals.
+	TexGlyphX        = 256
+	TexGlyphWidth    = 24
+	TexGlyphHeight   = 32
+	TexGlyphsPerLine = 32
 )
 
 func (w *World) loadTextures() {
@@ -373,6 +722,40 @@ func (w *World) loadTextures() {
 		texSwitch7: {t, image.Rect(TexSwitchSize*6, TexBlockSize*2, TexSwitchSize*7-1, TexBlockSize*2+TexSwitchSize)},
 		texSwitch8: {t, image.Rect(TexSwitchSize*7, TexBlockSize*2, TexSwitchSize*8-1, TexBlockSize*2+TexSwitchSize)},
 		texSwitch9: {t, image.Rect(TexSwitchSize*8, TexBlockSize*2, TexSwitchSize*9-1, TexBlockSize*2+TexSwitchSize)},
+		// Switches with other rotation directions
+		texSwitchCCW:  {t, image.Rect(0, TexIconsY, TexIconSize, TexIconsY+TexIconSize)},
+		texSwitchBoth: {t, image.Rect(TexIconSize, TexIconsY, TexIconSize*2, TexIconsY+TexIconSize)},
+		// Locked block overlay
+		texLock: {t, image.Rect(TexIconSize*2, TexIconsY, TexIconSize*3, TexIconsY+TexIconSize)},
+		// Shift arrows
+		texArrowRight: {t, image.Rect(TexIconSize*3, TexIconsY, TexIconSize*4, TexIconsY+TexIconSize)},
+		texArrowLeft:  {t, image.Rect(TexIconSize*4, TexIconsY, TexIconSize*5, TexIconsY+TexIconSize)},
+		texArrowDown:  {t, image.Rect(TexIconSize*5, TexIconsY, TexIconSize*6, TexIconsY+TexIconSize)},
+		texArrowUp:    {t, image.Rect(TexIconSize*6, TexIconsY, TexIconSize*7, TexIconsY+TexIconSize)},
+		// Score stars
+		texStarFull:  {t, image.Rect(TexIconSize*7, TexIconsY, TexIconSize*8, TexIconsY+TexIconSize)},
+		texStarEmpty: {t, image.Rect(TexIconSize*8, TexIconsY, TexIconSize*9, TexIconsY+TexIconSize)},
+		// History buttons
+		texUndo:    {t, image.Rect(TexIconSize*10, TexIconsY, TexIconSize*11, TexIconsY+TexIconSize)},
+		texRedo:    {t, image.Rect(TexIconSize*11, TexIconsY, TexIconSize*12, TexIconsY+TexIconSize)},
+		texRestart: {t, image.Rect(TexIconSize*13, TexIconsY, TexIconSize*14, TexIconsY+TexIconSize)},
+		texMenu:    {t, image.Rect(TexIconSize*12, TexIconsY, TexIconSize*13, TexIconsY+TexIconSize)},
+		// Lives
+		texHeart: {t, image.Rect(TexIconSize*9, TexIconsY, TexIconSize*10, TexIconsY+TexIconSize)},
+		// Code prompt buttons
+		texCode:  {t, image.Rect(TexIconSize*14, TexIconsY, TexIconSize*15, TexIconsY+TexIconSize)},
+		texErase: {t, image.Rect(TexIconSize*15, TexIconsY, TexIconSize*16, TexIconsY+TexIconSize)},
+		texOk:    {t, image.Rect(TexIconSize*16, TexIconsY, TexIconSize*17, TexIconsY+TexIconSize)},
+		// Time-attack toggle
+		texClock:    {t, image.Rect(TexIconSize*17, TexIconsY, TexIconSize*18, TexIconsY+TexIconSize)},
+		texClockOff: {t, image.Rect(TexIconSize*18, TexIconsY, TexIconSize*19, TexIconsY+TexIconSize)},
+		// Pattern goals
+		// Ignored cells of the win
+		texOutline: {t, image.Rect(0, TexGoalsY, TexGoalSize, TexGoalsY+TexGoalSize)},
+		// Blocks of the hovered switch
+		texHighlight:      {t, image.Rect(TexGoalSize, TexGoalsY, TexGoalSize*2, TexGoalsY+TexGoalSize)},
+		texGoalRows:       {t, image.Rect(TexGoalSize*2, TexGoalsY, TexGoalSize*3, TexGoalsY+TexGoalSize)},
+		texGoalNoAdjacent: {t, image.Rect(TexGoalSize*3, TexGoalsY, TexGoalSize*4, TexGoalsY+TexGoalSize)},
 		// Win text texture
 		texWinTxt: {t, image.Rect(0, TexBlockSize*2+TexSwitchSize, TexWinWidth, TexBlockSize*2+TexSwitchSize+TexWinHeight)},
 		// Level text texture
@@ -381,6 +764,13 @@ func (w *World) loadTextures() {
 		texLooseTxt: {t, image.Rect(0, TexBlockSize*2+TexSwitchSize+TexWinHeight, TexGameoverWidth, TexBlockSize*2+TexSwitchSize+TexWinHeight+TexGameoverHeight)},
 	}
 
+	// Load the hex block textures
+	for i := texBlockRed; i <= texBlockOtherWhite; i++ {
+		r := w.texs[i].R
+		k := r.Min.X/TexBlockSize + r.Min.Y/TexBlockSize*8
+		w.texs[texHexRed+i-texBlockRed] = sprite.SubTex{t, image.Rect(k*TexHexSize, TexHexY, (k+1)*TexHexSize, TexHexY+TexHexSize)}
+	}
+
 	// Load the number textures
 	numStartX := 0
 	numStartY := TexBlockSize*2 + TexSwitchSize + TexWinHeight + TexGameoverHeight
@@ -392,4 +782,12 @@ func (w *World) loadTextures() {
 		numStartX += TexCharWidth
 		texId++
 	}
+
+	// Load the code character textures
+	w.glyphs = make([]sprite.SubTex, len(codeAlphabet))
+	for i := range w.glyphs {
+		x := TexGlyphX + i%TexGlyphsPerLine*TexGlyphWidth
+		y := TexGoalsY + i/TexGlyphsPerLine*TexGlyphHeight
+		w.glyphs[i] = sprite.SubTex{t, image.Rect(x, y, x+TexGlyphWidth, y+TexGlyphHeight)}
+	}
 }